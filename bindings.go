@@ -0,0 +1,94 @@
+package main
+
+import "strconv"
+
+// binding describes how the generated code binds a field type to a flag
+type binding struct {
+	// Func registers the flag on a pflag FlagSet, as in IntVarP(&v, name,
+	// short, default, usage)
+	Func string
+	// StdFunc registers the flag on a flag FlagSet. It is empty when the
+	// flag package has no Var for the type.
+	StdFunc string
+	// Value binds the type through a flag.Value generated next to the
+	// command instead of a Var function
+	Value bool
+	// Zero is the Go zero value, registered when there is no default and
+	// compared against to find missing required flags
+	Zero string
+	// ZeroText is the zero value as the flag packages print it. A flag's
+	// default is left out of the help when it reads as the zero value.
+	ZeroText string
+	// Literal renders a default: value as a Go expression
+	Literal func(value string) string
+	// Bits is the size of integer types, to check that defaults fit
+	Bits     int
+	Unsigned bool
+}
+
+// typeBindings lists every field type the CLI template generates flags
+// for. Adding a type only takes an entry here, and fields of any other type
+// fail generation.
+var typeBindings = map[string]binding{
+	"string": {Func: "StringVarP", StdFunc: "StringVar", Zero: `""`, Literal: strconv.Quote},
+	"bool":   {Func: "BoolVarP", StdFunc: "BoolVar", Zero: "false", ZeroText: "false", Literal: verbatim},
+	"int":    {Func: "IntVarP", StdFunc: "IntVar", Zero: "0", ZeroText: "0", Literal: verbatim, Bits: strconv.IntSize},
+	"int8":   {Func: "Int8VarP", Zero: "0", ZeroText: "0", Literal: verbatim, Bits: 8},
+	"int16":  {Func: "Int16VarP", Zero: "0", ZeroText: "0", Literal: verbatim, Bits: 16},
+	"int32":  {Func: "Int32VarP", Zero: "0", ZeroText: "0", Literal: verbatim, Bits: 32},
+	"int64":  {Func: "Int64VarP", StdFunc: "Int64Var", Zero: "0", ZeroText: "0", Literal: verbatim, Bits: 64},
+	"uint":   {Func: "UintVarP", StdFunc: "UintVar", Zero: "0", ZeroText: "0", Literal: verbatim, Bits: strconv.IntSize, Unsigned: true},
+	"uint8":  {Func: "Uint8VarP", Zero: "0", ZeroText: "0", Literal: verbatim, Bits: 8, Unsigned: true},
+	"uint16": {Func: "Uint16VarP", Zero: "0", ZeroText: "0", Literal: verbatim, Bits: 16, Unsigned: true},
+	"uint32": {Func: "Uint32VarP", Zero: "0", ZeroText: "0", Literal: verbatim, Bits: 32, Unsigned: true},
+	"uint64": {Func: "Uint64VarP", StdFunc: "Uint64Var", Zero: "0", ZeroText: "0", Literal: verbatim, Bits: 64, Unsigned: true},
+	"[]string": {
+		Func: "StringSliceVarP", Zero: "nil", ZeroText: "[]", Literal: stringsLiteral,
+	},
+	// time.Time is parsed with the field's layout, RFC 3339 by default
+	"time.Time": {Value: true, Zero: "time.Time{}"},
+}
+
+// verbatim renders defaults that are already Go literals, such as numbers
+// and normalized bools
+func verbatim(value string) string {
+	return value
+}
+
+// stringsLiteral renders a []string default holding the value. default:[]
+// renders an empty slice instead, for code telling an empty list from a nil
+// one that wasn't configured.
+func stringsLiteral(value string) string {
+	if value == "[]" {
+		return "[]string{}"
+	}
+	return "[]string{" + strconv.Quote(value) + "}"
+}
+
+// Binding returns how the field's type is bound to a flag
+func (f FieldInfo) Binding() binding {
+	return typeBindings[f.Type]
+}
+
+// DefaultLiteral returns the Go expression of the field's default, or of
+// the zero value without one
+func (f FieldInfo) DefaultLiteral() string {
+	b := f.Binding()
+	if f.DefaultValue == "" || b.Literal == nil {
+		return b.Zero
+	}
+	return b.Literal(f.DefaultValue)
+}
+
+// isInteger reports whether the type is one of the supported integer types
+func isInteger(fieldType string) bool {
+	return typeBindings[fieldType].Bits > 0
+}
+
+// stdflagSupports reports whether the stdflag backend can bind a flag of
+// the given type, which leaves out slices and fixed-width integers below 64
+// bits
+func stdflagSupports(fieldType string) bool {
+	b := typeBindings[fieldType]
+	return b.StdFunc != "" || b.Value
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFixedWidthIntegers(t *testing.T) {
+	dir := generate(t, `package main
+
+type ServeArgs struct {
+	Port  uint16 `+"`cli:\"port,default:8080\"`"+`
+	Level int8
+	Size  uint64
+}
+`, "serve", "Starts an http server")
+	app := filepath.Join(dir, "cmd", "serve")
+	writeHandler(t, app, "serve", `fmt.Printf("%T %d %T %d\n", args.Port, args.Port, args.Level, args.Level)`)
+	bin := buildCommand(t, app)
+
+	if out, err := runCommand(bin, "--port=443", "--level=-3"); err != nil || out != "uint16 443 int8 -3\n" {
+		t.Errorf("--port=443 --level=-3 gave %q, %v, want \"uint16 443 int8 -3\\n\"", out, err)
+	}
+	if out, err := runCommand(bin, "--port=70000"); err == nil {
+		t.Errorf("--port=70000 overflowing a uint16 succeeded:\n%s", out)
+	}
+}
+
+func TestFixedWidthDefaultChecked(t *testing.T) {
+	generateFails(t, `package main
+
+type ServeArgs struct {
+	Port uint16 `+"`cli:\"port,default:70000\"`"+`
+}
+`, `default "70000" is not a valid uint16`, "serve", "Starts an http server")
+}
+
+func TestTimeFields(t *testing.T) {
+	dir := generate(t, `package main
+
+import "time"
+
+type ReportArgs struct {
+	Since time.Time `+"`cli:\"since,default:today,layout:2006-01-02\"`"+`
+	Until time.Time `+"`cli:\"until,default:now\"`"+`
+	At    time.Time `+"`cli:\"at,default:2024-01-02T03:04:05Z\"`"+`
+}
+`, "report", "Prints a report")
+	app := filepath.Join(dir, "cmd", "report")
+	writeHandler(t, app, "report", `now := time.Now()
+today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.Local)
+fmt.Println(args.Since.Equal(today), now.Sub(args.Until) < time.Minute, args.At.UTC().Format(time.RFC3339))`, "time")
+	bin := buildCommand(t, app)
+
+	if out, err := runCommand(bin); err != nil || out != "true true 2024-01-02T03:04:05Z\n" {
+		t.Errorf("the defaults gave %q, %v, want \"true true 2024-01-02T03:04:05Z\\n\"", out, err)
+	}
+	if out, err := runCommand(bin, "--since=2024-05-06"); err != nil || !strings.HasPrefix(out, "false") {
+		t.Errorf("--since=2024-05-06 gave %q, %v", out, err)
+	}
+	if out, err := runCommand(bin, "--since=06/05/2024"); err == nil {
+		t.Errorf("--since in another layout succeeded:\n%s", out)
+	}
+}
+
+func TestUnsupportedTypes(t *testing.T) {
+	for _, typ := range []string{"[]bool", "*string", "map[string]int", "[]*int"} {
+		generateFails(t, "package main\n\ntype ServeArgs struct {\n\tValue "+typ+"\n}\n", "field Value: unsupported type "+typ, "serve", "Starts an http server")
+	}
+
+	// Skipped fields may have any type
+	source := "package main\n\ntype ServeArgs struct {\n\tPort int\n\tCache map[string]int `cli:\"-\"`\n}\n"
+	cligen(t, writeFiles(t, map[string]string{"source.go": source}), "serve", "Starts an http server")
+}
+
+func TestBoolDefaults(t *testing.T) {
+	dir := generate(t, `package main
+
+type ServeArgs struct {
+	A bool `+"`cli:\"a,default:yes\"`"+`
+	B bool `+"`cli:\"b,default:off\"`"+`
+	C bool `+"`cli:\"c,default:1\"`"+`
+	D bool `+"`cli:\"d,default:no\"`"+`
+}
+`, "serve", "Starts an http server")
+	app := filepath.Join(dir, "cmd", "serve")
+	writeHandler(t, app, "serve", `fmt.Println(args.A, args.B, args.C, args.D)`)
+	bin := buildCommand(t, app)
+
+	if out, err := runCommand(bin); err != nil || out != "true false true false\n" {
+		t.Errorf("the defaults gave %q, %v, want \"true false true false\\n\"", out, err)
+	}
+	generateFails(t, "package main\n\ntype ServeArgs struct {\n\tA bool `cli:\"a,default:maybe\"`\n}\n", `default "maybe" is not a valid bool`, "serve", "Starts an http server")
+}
+
+func TestListTypes(t *testing.T) {
+	out := cligen(t, t.TempDir(), "--list-types")
+	for name := range typeBindings {
+		if !strings.Contains(out, "  "+name) {
+			t.Errorf("--list-types lacks %s:\n%s", name, out)
+		}
+	}
+	if !strings.Contains(out, "int8       (not as a flag with --backend=stdflag)") {
+		t.Errorf("--list-types doesn't mark the types stdflag can't bind:\n%s", out)
+	}
+}
+
+func TestEveryBindingBuilds(t *testing.T) {
+	for _, backend := range []string{"pflag", "stdflag"} {
+		source := "package main\n\nimport \"time\"\n\nvar _ time.Time\n\ntype ServeArgs struct {\n"
+		for name := range typeBindings {
+			if backend == "pflag" || stdflagSupports(name) {
+				field := "Field" + strings.NewReplacer("[]", "Slice", ".", "").Replace(upperFirst(name))
+				source += "\t" + field + " " + name + "\n"
+			}
+		}
+		source += "}\n"
+		dir := generate(t, source, "--backend="+backend, "serve", "Starts an http server")
+		buildCommand(t, filepath.Join(dir, "cmd", "serve"))
+	}
+}
+
+func TestTypeOverride(t *testing.T) {
+	dir := generate(t, `package main
+
+type Port = int
+
+type ServeArgs struct {
+	Port Port `+"`cli:\"port,type:int,default:8080\"`"+`
+}
+`, "serve", "Starts an http server")
+	app := filepath.Join(dir, "cmd", "serve")
+	writeHandler(t, app, "serve", `fmt.Printf("%T %d\n", args.Port, args.Port)`)
+	bin := buildCommand(t, app)
+
+	if out, err := runCommand(bin, "--port=443"); err != nil || out != "int 443\n" {
+		t.Errorf("--port=443 gave %q, %v, want \"int 443\\n\"", out, err)
+	}
+
+	// A defined type isn't visible from the generated package either, so the
+	// field is declared as the override there too
+	dir = generate(t, `package main
+
+type Port int
+
+type ServeArgs struct {
+	Port Port `+"`cli:\"port,type:int,default:8080\"`"+`
+}
+`, "serve", "Starts an http server")
+	app = filepath.Join(dir, "cmd", "serve")
+	writeHandler(t, app, "serve", `fmt.Printf("%T %d\n", args.Port, args.Port)`)
+	bin = buildCommand(t, app)
+
+	if out, err := runCommand(bin); err != nil || out != "int 8080\n" {
+		t.Errorf("the default for type Port int gave %q, %v, want \"int 8080\\n\"", out, err)
+	}
+	if code := readFile(t, filepath.Join(app, "main.go")); !strings.Contains(code, "Port int") {
+		t.Errorf("the Port field isn't declared as int:\n%s", code)
+	}
+
+	generateFails(t, `package main
+
+type ServeArgs struct {
+	Port int `+"`cli:\"port,type:complex128\"`"+`
+}
+`, "type:complex128 is not a supported type", "serve", "Starts an http server")
+}
+
+func TestEmptySliceDefault(t *testing.T) {
+	dir := generate(t, `package main
+
+type BuildArgs struct {
+	Tags   []string `+"`cli:\"tags,default:[]\"`"+`
+	Labels []string `+"`cli:\"labels\"`"+`
+}
+`, "build", "Builds the site")
+	app := filepath.Join(dir, "cmd", "build")
+	writeHandler(t, app, "build", `fmt.Println(args.Tags == nil, len(args.Tags), args.Labels == nil)`)
+	bin := buildCommand(t, app)
+
+	if out, err := runCommand(bin); err != nil || out != "false 0 true\n" {
+		t.Errorf("the defaults gave %q, %v, want \"false 0 true\\n\"", out, err)
+	}
+	if out, err := runCommand(bin, "--tags=a,b"); err != nil || out != "false 2 true\n" {
+		t.Errorf("--tags=a,b gave %q, %v, want \"false 2 true\\n\"", out, err)
+	}
+}
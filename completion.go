@@ -0,0 +1,300 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// completionFlag is a flag as the completion scripts offer it
+type completionFlag struct {
+	Names   []string // --long, -s and the --aliases
+	Long    string
+	Short   string
+	Aliases []string
+	Help    string
+	Options []string
+	// Value is set for flags taking an argument, unlike bools and counts
+	Value bool
+}
+
+// completionFlags lists the flags of a command for the completion scripts
+func (g *Generator) completionFlags(fields []FieldInfo) []completionFlag {
+	var flags []completionFlag
+	for _, field := range fields {
+		if !field.IsFlag() {
+			continue
+		}
+
+		help, _, _ := strings.Cut(field.Description(), "\n")
+		flag := completionFlag{
+			Long:    field.CLIName,
+			Short:   field.ShortFlag,
+			Aliases: field.Aliases,
+			Help:    help,
+			Options: field.Options,
+			Value:   field.Type != "bool" && !field.Count,
+		}
+		flag.Names = append(flag.Names, "--"+field.CLIName)
+		if field.ShortFlag != "" {
+			flag.Names = append(flag.Names, "-"+field.ShortFlag)
+		}
+		for _, alias := range field.Aliases {
+			flag.Names = append(flag.Names, "--"+alias)
+		}
+		flags = append(flags, flag)
+	}
+
+	if g.Color {
+		flags = append(flags, completionFlag{
+			Names:   []string{"--color"},
+			Long:    "color",
+			Help:    "When to color errors",
+			Options: []string{"auto", "always", "never"},
+			Value:   true,
+		})
+	}
+	if g.WriteConfig {
+		flags = append(flags, completionFlag{
+			Names: []string{"--write-config"},
+			Long:  "write-config",
+			Help:  "Write a configuration file template",
+			Value: true,
+		})
+	}
+	return append(flags, completionFlag{Names: []string{"--help", "-h"}, Long: "help", Short: "h", Help: "Show help"})
+}
+
+// completionArgs lists the options offered for each positional argument of
+// a command, in order, with none for the arguments completing to file names
+func completionArgs(fields []FieldInfo) [][]string {
+	var args [][]string
+	for _, field := range positionals(fields) {
+		args = append(args, field.Options)
+	}
+	return args
+}
+
+// anyOptions reports whether any positional argument completes to options
+func anyOptions(args [][]string) bool {
+	for _, options := range args {
+		if len(options) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// completionScripts renders the static completion scripts of a program.
+// With subcommands the first word completes to a command name.
+func (g *Generator) completionScripts(program string, commands []Command, subcommands bool) map[string]string {
+	bash := bashCompletion(g, program, commands, subcommands)
+	return map[string]string{
+		"bash": bash,
+		// zsh runs the bash script through its bash compatibility layer
+		"zsh":  "#compdef " + program + "\n\nautoload -U +X bashcompinit && bashcompinit\n\n" + bash,
+		"fish": fishCompletion(g, program, commands, subcommands),
+	}
+}
+
+// bashCompletion renders the bash completion script
+func bashCompletion(g *Generator, program string, commands []Command, subcommands bool) string {
+	var b strings.Builder
+	function := "_" + shellIdent(program)
+
+	fmt.Fprintf(&b, "# bash completion for %s, generated by cligen\n\n", program)
+	fmt.Fprintf(&b, "%s() {\n", function)
+	b.WriteString("    local cur=\"${COMP_WORDS[COMP_CWORD]}\" prev=\"${COMP_WORDS[COMP_CWORD-1]}\"\n")
+
+	if !subcommands {
+		writeBashFlags(&b, g.completionFlags(commands[0].Fields), "    ")
+		writeBashArgs(&b, g.completionFlags(commands[0].Fields), commands[0].Fields, 1, "    ")
+	} else {
+		names := []string{"help"}
+		for _, cmd := range commands {
+			names = append(names, cmd.Name)
+		}
+		b.WriteString("    if [ \"$COMP_CWORD\" -eq 1 ]; then\n")
+		fmt.Fprintf(&b, "        COMPREPLY=($(compgen -W %s -- \"$cur\"))\n", shellQuote(strings.Join(names, " ")))
+		b.WriteString("        return\n")
+		b.WriteString("    fi\n")
+		b.WriteString("    case \"${COMP_WORDS[1]}\" in\n")
+		for _, cmd := range commands {
+			fmt.Fprintf(&b, "    %s)\n", cmd.Name)
+			writeBashFlags(&b, g.completionFlags(cmd.Fields), "        ")
+			writeBashArgs(&b, g.completionFlags(cmd.Fields), cmd.Fields, 2, "        ")
+			b.WriteString("        ;;\n")
+		}
+		b.WriteString("    esac\n")
+	}
+
+	b.WriteString("}\n\n")
+	fmt.Fprintf(&b, "complete -o default -F %s %s\n", function, program)
+	return b.String()
+}
+
+// writeBashFlags completes the values of flags with options after the
+// flag, and flag names for words starting with a dash. Anything else falls
+// back to file names.
+func writeBashFlags(b *strings.Builder, flags []completionFlag, indent string) {
+	var names []string
+	var cases []string
+	for _, flag := range flags {
+		names = append(names, flag.Names...)
+		if len(flag.Options) > 0 {
+			cases = append(cases, fmt.Sprintf("%s%s) COMPREPLY=($(compgen -W %s -- \"$cur\")); return ;;\n",
+				indent, strings.Join(flag.Names, "|"), shellQuote(strings.Join(flag.Options, " "))))
+		}
+	}
+
+	if len(cases) > 0 {
+		fmt.Fprintf(b, "%scase \"$prev\" in\n", indent)
+		for _, c := range cases {
+			b.WriteString(c)
+		}
+		fmt.Fprintf(b, "%sesac\n", indent)
+	}
+	fmt.Fprintf(b, "%sif [[ \"$cur\" == -* ]]; then\n", indent)
+	fmt.Fprintf(b, "%s    COMPREPLY=($(compgen -W %s -- \"$cur\"))\n", indent, shellQuote(strings.Join(names, " ")))
+	fmt.Fprintf(b, "%s    return\n", indent)
+	fmt.Fprintf(b, "%sfi\n", indent)
+}
+
+// writeBashArgs completes the options of the positional argument at the
+// cursor, counting the words from first that are neither flags nor the
+// values of flags taking one. Bash splits --name=value into three words,
+// so a flag followed by = skips both.
+func writeBashArgs(b *strings.Builder, flags []completionFlag, fields []FieldInfo, first int, indent string) {
+	args := completionArgs(fields)
+	if !anyOptions(args) {
+		return
+	}
+
+	var valued []string
+	for _, flag := range flags {
+		if flag.Value {
+			valued = append(valued, flag.Names...)
+		}
+	}
+
+	fmt.Fprintf(b, "%slocal i arg=0\n", indent)
+	fmt.Fprintf(b, "%sfor ((i = %d; i < COMP_CWORD; i++)); do\n", indent, first)
+	fmt.Fprintf(b, "%s    case \"${COMP_WORDS[i]}\" in\n", indent)
+	if len(valued) > 0 {
+		fmt.Fprintf(b, "%s    %s) [[ \"${COMP_WORDS[i+1]}\" == = ]] && ((i++)); ((i++)) ;;\n", indent, strings.Join(valued, "|"))
+	}
+	fmt.Fprintf(b, "%s    -*|=) ;;\n", indent)
+	fmt.Fprintf(b, "%s    *) ((arg++)) ;;\n", indent)
+	fmt.Fprintf(b, "%s    esac\n", indent)
+	fmt.Fprintf(b, "%sdone\n", indent)
+	// Past the cursor, the word being completed is the value of a flag
+	fmt.Fprintf(b, "%s[ \"$i\" -gt \"$COMP_CWORD\" ] && return\n", indent)
+	fmt.Fprintf(b, "%scase \"$arg\" in\n", indent)
+	for i, options := range args {
+		if len(options) == 0 {
+			continue
+		}
+		fmt.Fprintf(b, "%s%d) COMPREPLY=($(compgen -W %s -- \"$cur\")) ;;\n", indent, i, shellQuote(strings.Join(options, " ")))
+	}
+	fmt.Fprintf(b, "%sesac\n", indent)
+}
+
+// fishCompletion renders the fish completion script
+func fishCompletion(g *Generator, program string, commands []Command, subcommands bool) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# fish completion for %s, generated by cligen\n\n", program)
+
+	if !subcommands {
+		writeFishFlags(&b, program, "", g.completionFlags(commands[0].Fields))
+		writeFishArgs(&b, program, "", commands[0].Fields, 1)
+		return b.String()
+	}
+
+	for _, cmd := range commands {
+		fmt.Fprintf(&b, "complete -c %s -n __fish_use_subcommand -x -a %s -d %s\n", program, cmd.Name, shellQuote(cmd.Help))
+	}
+	for _, cmd := range commands {
+		b.WriteString("\n")
+		condition := shellQuote("__fish_seen_subcommand_from " + cmd.Name)
+		writeFishFlags(&b, program, condition, g.completionFlags(cmd.Fields))
+		writeFishArgs(&b, program, "__fish_seen_subcommand_from "+cmd.Name+"; and ", cmd.Fields, 2)
+	}
+	return b.String()
+}
+
+// writeFishArgs writes a complete line offering the options of each
+// positional argument that has them, once the words before the cursor that
+// aren't flags reach its position. first counts the program, and the
+// command with subcommands, which the guard checks for. Unlike bash, the
+// values of flags are counted as words.
+func writeFishArgs(b *strings.Builder, program, guard string, fields []FieldInfo, first int) {
+	for i, options := range completionArgs(fields) {
+		if len(options) == 0 {
+			continue
+		}
+		condition := fmt.Sprintf(`%stest (count (commandline -opc | string match -v -- "-*")) -eq %d`, guard, first+i)
+		fmt.Fprintf(b, "complete -c %s -n %s -f -a %s\n", program, shellQuote(condition), shellQuote(strings.Join(options, " ")))
+	}
+}
+
+// writeFishFlags writes a complete line for each flag, restricted to the
+// given condition when set
+func writeFishFlags(b *strings.Builder, program, condition string, flags []completionFlag) {
+	for _, flag := range flags {
+		fmt.Fprintf(b, "complete -c %s", program)
+		if condition != "" {
+			fmt.Fprintf(b, " -n %s", condition)
+		}
+		fmt.Fprintf(b, " -l %s", flag.Long)
+		if flag.Short != "" {
+			fmt.Fprintf(b, " -s %s", flag.Short)
+		}
+		for _, alias := range flag.Aliases {
+			fmt.Fprintf(b, " -l %s", alias)
+		}
+		switch {
+		case len(flag.Options) > 0:
+			fmt.Fprintf(b, " -x -a %s", shellQuote(strings.Join(flag.Options, " ")))
+		case flag.Value:
+			b.WriteString(" -r")
+		}
+		if flag.Help != "" {
+			fmt.Fprintf(b, " -d %s", shellQuote(flag.Help))
+		}
+		b.WriteString("\n")
+	}
+}
+
+// shellQuote quotes s as a single word for bash and fish
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// shellIdent turns a program name into a shell function name
+func shellIdent(name string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '_' || ('a' <= r && r <= 'z') || ('A' <= r && r <= 'Z') || ('0' <= r && r <= '9') {
+			return r
+		}
+		return '_'
+	}, name)
+}
+
+// generateCompletion writes the completion helper of the program next to
+// its main when --with-completion is given
+func (g *Generator) generateCompletion(program string, commands []Command, subcommands bool) error {
+	if !g.Completion {
+		return nil
+	}
+
+	data := templateData{
+		Program:    program,
+		Backend:    g.Backend,
+		Invocation: g.Invocation,
+		Source:     g.SourceFile,
+	}
+	if g.Backend != "cobra" {
+		data.Completions = g.completionScripts(program, commands, subcommands)
+	}
+	return g.renderTemplate("completion", filepath.Join(g.outputDir(), "completion.go"), data)
+}
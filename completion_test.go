@@ -0,0 +1,94 @@
+package main
+
+import (
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const deploySource = `package main
+
+type DeployArgs struct {
+	Env      string ` + "`cli:\"env,e,options:dev|staging|prod\"`" + `
+	Platform string ` + "`cli:\"platform,positional,options:linux|darwin\"`" + `
+	Dir      string ` + "`cli:\"dir,positional\"`" + `
+}
+`
+
+func TestCobraCompletesOptions(t *testing.T) {
+	dir := generate(t, deploySource, "--backend=cobra", "deploy", "Deploys")
+	bin := buildCommand(t, filepath.Join(dir, "cmd", "deploy"))
+
+	for _, tt := range []struct {
+		args []string
+		want []string
+	}{
+		{[]string{"__complete", "--env", ""}, []string{"dev", "staging", "prod"}},
+		{[]string{"__complete", ""}, []string{"linux", "darwin", ":4"}},
+		{[]string{"__complete", "linux", ""}, []string{":0"}},
+		{[]string{"__complete", "linux", "site", ""}, []string{":4"}},
+	} {
+		out, err := runCommand(bin, tt.args...)
+		if err != nil {
+			t.Fatalf("%v: %v\n%s", tt.args, err, out)
+		}
+		for _, want := range tt.want {
+			if !strings.Contains(out, want+"\n") {
+				t.Errorf("%v lacks %q:\n%s", tt.args, want, out)
+			}
+		}
+	}
+}
+
+// bashComplete loads the bash completion script of bin and returns the
+// candidates it offers for the last of words, which start with the command
+// name
+func bashComplete(t *testing.T, bin string, words ...string) []string {
+	t.Helper()
+	if _, err := exec.LookPath("bash"); err != nil {
+		t.Skip("bash is not installed")
+	}
+	script := `source <("$0" --generate-completion=bash) && COMP_WORDS=("${@:1}") && COMP_CWORD=$(($# - 1)) && _` + words[0] + ` && printf '%s\n' "${COMPREPLY[@]}"`
+	out, err := exec.Command("bash", append([]string{"-c", script, bin}, words...)...).CombinedOutput()
+	if err != nil {
+		t.Fatalf("completing %q: %v\n%s", words, err, out)
+	}
+	return strings.Fields(string(out))
+}
+
+func TestCompletionScripts(t *testing.T) {
+	dir := generate(t, deploySource, "--with-completion", "deploy", "Deploys")
+	bin := buildCommand(t, filepath.Join(dir, "cmd", "deploy"))
+
+	for _, tt := range []struct {
+		words []string
+		want  string
+	}{
+		{[]string{"deploy", "--e"}, "--env"},
+		{[]string{"deploy", "--env", ""}, "dev staging prod"},
+		{[]string{"deploy", "-e", "st"}, "staging"},
+		{[]string{"deploy", ""}, "linux darwin"},
+		{[]string{"deploy", "--env", "dev", "da"}, "darwin"},
+	} {
+		if got := strings.Join(bashComplete(t, bin, tt.words...), " "); got != tt.want {
+			t.Errorf("completing %q offered %q, want %q", tt.words, got, tt.want)
+		}
+	}
+
+	for _, shell := range []string{"zsh", "fish"} {
+		out, err := runCommand(bin, "--generate-completion="+shell)
+		if err != nil || !strings.Contains(out, "dev staging prod") {
+			t.Errorf("the %s script lacks the options of --env (%v):\n%s", shell, err, out)
+		}
+		if !strings.Contains(out, "'linux darwin'") {
+			t.Errorf("the %s script lacks the options of the platform argument:\n%s", shell, out)
+		}
+	}
+	if out, _ := runCommand(bin, "--help"); strings.Contains(out, "generate-completion") {
+		t.Errorf("--help lists the hidden --generate-completion:\n%s", out)
+	}
+	if out, _ := runCommand(bin, "completion", "--help"); !strings.Contains(out, "source <(deploy --generate-completion=bash)") {
+		t.Errorf("completion --help lacks the install instructions:\n%s", out)
+	}
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+)
+
+// configNode is a key of the --write-config template: a flag, or a nested
+// struct holding the keys of its flags
+type configNode struct {
+	key   string
+	field *FieldInfo
+	nodes []*configNode
+}
+
+// configTemplate renders the YAML file written by --write-config, as the Go
+// string literal the template embeds. Every flag is a key set to its
+// default under a comment with its help. The keys are laid out like the
+// --struct-tags, so the file decodes back into the command struct
+// generated with --struct-tags=yaml.
+func (g *Generator) configTemplate(command string, fields []FieldInfo) string {
+	if !g.WriteConfig {
+		return ""
+	}
+
+	root := &configNode{}
+	for i := range fields {
+		if !fields[i].IsFlag() {
+			continue
+		}
+		path := strings.Split(fields[i].Name, ".")
+		node := root
+		for j := range path[:len(path)-1] {
+			nested := strings.Join(path[:j+1], ".")
+			node = node.child(g.tagKey(nested, g.prefixes[nested]))
+		}
+		node.nodes = append(node.nodes, &configNode{key: g.tagKey(fields[i].Name, fields[i].CLIName), field: &fields[i]})
+	}
+
+	var b strings.Builder
+	b.WriteString("# Configuration of " + command + ", written by --write-config. Every\n")
+	b.WriteString("# key is set to the default of the flag of the same name.\n")
+	for _, node := range root.nodes {
+		b.WriteString("\n")
+		node.write(&b, "")
+	}
+
+	text := b.String()
+	if strings.Contains(text, "`") {
+		return strconv.Quote(text)
+	}
+	return "`" + text + "`"
+}
+
+// child returns the nested struct node of the given key, adding it after
+// the nodes so far the first time
+func (n *configNode) child(key string) *configNode {
+	for _, node := range n.nodes {
+		if node.field == nil && node.key == key {
+			return node
+		}
+	}
+	node := &configNode{key: key}
+	n.nodes = append(n.nodes, node)
+	return node
+}
+
+// write renders the node at the given indentation. A flag without a
+// default that YAML can hold, such as one from a default:func:, is written
+// commented out so decoding the file leaves it alone.
+func (n *configNode) write(b *strings.Builder, indent string) {
+	if n.field == nil {
+		b.WriteString(indent + n.key + ":\n")
+		for _, node := range n.nodes {
+			node.write(b, indent+"  ")
+		}
+		return
+	}
+
+	f := n.field
+	var notes []string
+	if f.Required {
+		notes = append(notes, "required")
+	}
+	if len(f.Options) > 0 {
+		notes = append(notes, "one of: "+strings.Join(f.Options, ", "))
+	}
+	if f.DefaultFunc != "" {
+		notes = append(notes, "default: "+f.DefaultFunc+"()")
+	}
+	help := f.Description()
+	if len(notes) > 0 {
+		help = strings.TrimSpace(help + " (" + strings.Join(notes, "; ") + ")")
+	}
+	if help != "" {
+		for _, line := range strings.Split(help, "\n") {
+			b.WriteString(strings.TrimRight(indent+"# "+line, " ") + "\n")
+		}
+	}
+
+	value, ok := configValue(*f)
+	if !ok {
+		b.WriteString(indent + "# " + n.key + ":\n")
+		return
+	}
+	b.WriteString(indent + n.key + ": " + value + "\n")
+}
+
+// configValue renders the default of the flag as a YAML value. It reports
+// false when the flag has none that decodes to the same value.
+func configValue(f FieldInfo) (string, bool) {
+	switch {
+	case f.DefaultFunc != "":
+		return "", false
+	case f.Type == "[]string":
+		if f.DefaultValue == "" || f.DefaultValue == "[]" {
+			return "[]", true
+		}
+		return "[" + strconv.Quote(f.DefaultValue) + "]", true
+	case f.Type == "string":
+		return strconv.Quote(f.DefaultValue), true
+	case f.Type == "time.Time":
+		// YAML has no zero time and decodes times in RFC 3339 only
+		return strconv.Quote(f.DefaultValue), f.DefaultValue != "" && f.Layout == ""
+	case f.DefaultValue != "":
+		return f.DefaultValue, true
+	}
+	return f.Binding().ZeroText, true
+}
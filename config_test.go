@@ -0,0 +1,112 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestWriteConfig(t *testing.T) {
+	dir := generate(t, `package main
+
+type ServeArgs struct {
+	Port int    `+"`cli:\"port,default:8080,usage:Port to listen on\"`"+`
+	Env  string `+"`cli:\"env,required\"`"+`
+	TLS  struct {
+		Cert string `+"`cli:\"cert,default:a.pem\"`"+`
+	} `+"`cli:\"tls\"`"+`
+}
+`, "--with-write-config", "serve", "Starts an http server")
+	app := filepath.Join(dir, "cmd", "serve")
+	writeHandler(t, app, "serve", `fmt.Println("running")`)
+	bin := buildCommand(t, app)
+
+	out, err := runCommand(bin, "--write-config=-")
+	if err != nil || strings.Contains(out, "running") {
+		t.Fatalf("--write-config=- gave %v, want the template without running:\n%s", err, out)
+	}
+	if !strings.Contains(out, "# Port to listen on\nport: 8080\n") || !strings.Contains(out, "# (required)\nenv: \"\"\n") {
+		t.Errorf("the template lacks the help of its keys:\n%s", out)
+	}
+	var config map[string]any
+	if err := yaml.Unmarshal([]byte(out), &config); err != nil {
+		t.Fatalf("the template isn't YAML: %v\n%s", err, out)
+	}
+	want := map[string]any{"port": 8080, "env": "", "tls": map[string]any{"cert": "a.pem"}}
+	if !reflect.DeepEqual(config, want) {
+		t.Errorf("the template decodes to %v, want %v", config, want)
+	}
+
+	path := filepath.Join(dir, "config.yaml")
+	if out, err := runCommand(bin, "--write-config="+path); err != nil {
+		t.Fatalf("--write-config=%s: %v\n%s", path, err, out)
+	}
+	if got := readFile(t, path); !strings.Contains(got, "port: 8080") {
+		t.Errorf("%s holds:\n%s", path, got)
+	}
+	if out, err := runCommand(bin, "--write-config="+path); err == nil || !strings.Contains(out, "file exists") {
+		t.Errorf("writing over %s gave %v, want it refused:\n%s", path, err, out)
+	}
+}
+
+func TestWriteConfigDecodesIntoCommand(t *testing.T) {
+	dir := generate(t, `package main
+
+type ServeArgs struct {
+	Port    int      `+"`cli:\"port,default:8080,usage:Port to listen on\"`"+`
+	Host    string   `+"`cli:\"host,default:localhost\"`"+`
+	Tags    []string `+"`cli:\"tags,default:web\"`"+`
+	Verbose bool     `+"`cli:\"verbose\"`"+`
+	TLS     struct {
+		Cert string `+"`cli:\"cert,default:a.pem\"`"+`
+	} `+"`cli:\"tls\"`"+`
+}
+`, "--with-write-config", "--struct-tags=yaml", "serve", "Starts an http server")
+	app := filepath.Join(dir, "cmd", "serve")
+	bin := buildCommand(t, app)
+	if out, err := runCommand(bin, "--write-config="+filepath.Join(app, "config.yaml")); err != nil {
+		t.Fatalf("--write-config: %v\n%s", err, out)
+	}
+
+	// The template decodes into the command struct with the values parsing
+	// no arguments gives, so its keys and defaults can't drift from the flags
+	test := `package main
+
+import (
+	"os"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestConfigMatchesDefaults(t *testing.T) {
+	data, err := os.ReadFile("config.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var config ServeCommand
+	if err := yaml.Unmarshal(data, &config); err != nil {
+		t.Fatalf("the template doesn't decode into ServeCommand: %v\n%s", err, data)
+	}
+	defaults, err := NewServeCommandFromArgs(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.Port != defaults.Port || config.Host != defaults.Host || config.Verbose != defaults.Verbose || config.TLS.Cert != defaults.TLS.Cert {
+		t.Errorf("the template decodes to %+v, want the defaults %+v", config, defaults)
+	}
+	if len(config.Tags) != 1 || len(defaults.Tags) != 1 || config.Tags[0] != defaults.Tags[0] {
+		t.Errorf("the template has tags %q, want the default %q", config.Tags, defaults.Tags)
+	}
+}
+`
+	if err := os.WriteFile(filepath.Join(app, "serve_test.go"), []byte(test), 0644); err != nil {
+		t.Fatal(err)
+	}
+	goTool(t, app, "get", "gopkg.in/yaml.v3@v3.0.1")
+	goTool(t, app, "test", ".")
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"os"
+	"strings"
+)
+
+// dumpTypes prints the type declarations of the parsed source to stderr
+// with --debug-ast, to show which structs cligen found to match commands
+// against. A struct is listed with its number of fields, other types with
+// their underlying type.
+func (g *Generator) dumpTypes(node *ast.File) {
+	if !g.DebugAST {
+		return
+	}
+
+	fmt.Fprintf(os.Stderr, "debug-ast: type declarations of %s:\n", g.SourceFile)
+	for _, decl := range node.Decls {
+		genDecl, ok := decl.(*ast.GenDecl)
+		if !ok || genDecl.Tok != token.TYPE {
+			continue
+		}
+		for _, spec := range genDecl.Specs {
+			typeSpec, ok := spec.(*ast.TypeSpec)
+			if !ok {
+				continue
+			}
+			kind := g.getTypeString(typeSpec.Type)
+			if structType, ok := typeSpec.Type.(*ast.StructType); ok {
+				kind = fmt.Sprintf("struct with %d fields", structType.Fields.NumFields())
+				if structType.Fields.NumFields() == 1 {
+					kind = "struct with 1 field"
+				}
+			}
+			if typeSpec.TypeParams != nil {
+				kind += ", generic"
+			}
+			fmt.Fprintf(os.Stderr, "  %s%s: %s\n", g.position(typeSpec.Pos()), typeSpec.Name.Name, kind)
+		}
+	}
+}
+
+// dumpStruct prints the fields of the struct matched for a command to
+// stderr with --debug-ast, as declared: each field's names, its type as
+// cligen resolved it and its raw tag, before any tag is interpreted
+func (g *Generator) dumpStruct(command, structName string, structType *ast.StructType) {
+	if !g.DebugAST {
+		return
+	}
+
+	fmt.Fprintf(os.Stderr, "debug-ast: command %s matched struct %s:\n", command, structName)
+	for _, field := range structType.Fields.List {
+		names := "(embedded)"
+		if len(field.Names) > 0 {
+			idents := make([]string, len(field.Names))
+			for i, name := range field.Names {
+				idents[i] = name.Name
+			}
+			names = strings.Join(idents, ", ")
+		}
+		tag := "(no tag)"
+		if field.Tag != nil {
+			tag = field.Tag.Value
+		}
+		fmt.Fprintf(os.Stderr, "  %s%s: type %s, tag %s\n", g.position(field.Pos()), names, g.getTypeString(field.Type), tag)
+	}
+}
+
+// position renders pos as a file:line: prefix, or "" when the positions
+// aren't known, as for a --spec command
+func (g *Generator) position(pos token.Pos) string {
+	if g.fset == nil || !pos.IsValid() {
+		return ""
+	}
+	p := g.fset.Position(pos)
+	return fmt.Sprintf("%s:%d: ", p.Filename, p.Line)
+}
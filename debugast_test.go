@@ -0,0 +1,40 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDebugAST(t *testing.T) {
+	dir := writeFiles(t, map[string]string{"source.go": `package main
+
+type Port = int
+
+type Pair[T any] struct {
+	Value T
+}
+
+type ServeArgs struct {
+	Port       int ` + "`cli:\"port,p\"`" + `
+	Host, Bind string
+	TLS        struct{ Cert string }
+}
+`})
+	out := cligen(t, dir, "--debug-ast", "serve", "Starts an http server")
+	for _, want := range []string{
+		"debug-ast: type declarations of source.go:",
+		"source.go:3: Port: int\n",
+		"source.go:5: Pair: struct with 1 field, generic\n",
+		"source.go:9: ServeArgs: struct with 4 fields\n",
+		"debug-ast: command serve matched struct ServeArgs:",
+		"source.go:10: Port: type int, tag `cli:\"port,p\"`\n",
+		"source.go:11: Host, Bind: type string, tag (no tag)\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("--debug-ast lacks %q:\n%s", want, out)
+		}
+	}
+	if out := cligen(t, dir, "serve", "Starts an http server"); strings.Contains(out, "debug-ast") {
+		t.Errorf("the source was dumped without --debug-ast:\n%s", out)
+	}
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"go/scanner"
+)
+
+// Diagnostic describes a generation error for tools, located in the source
+// file when the error concerns a field or a syntax error
+type Diagnostic struct {
+	File    string `json:"file"`
+	Line    int    `json:"line,omitempty"`
+	Column  int    `json:"column,omitempty"`
+	Field   string `json:"field,omitempty"`
+	Message string `json:"message"`
+}
+
+// sourceError is an error about a field of the source file
+type sourceError struct {
+	field FieldInfo
+	err   error
+}
+
+func (e *sourceError) Error() string {
+	return e.err.Error()
+}
+
+func (e *sourceError) Unwrap() error {
+	return e.err
+}
+
+// fieldErrorf formats an error about a field, prefixed with its name
+func fieldErrorf(field FieldInfo, format string, args ...any) error {
+	return &sourceError{
+		field: field,
+		err:   fmt.Errorf("field %s: "+format, append([]any{field.Name}, args...)...),
+	}
+}
+
+// Diagnose describes an error returned by Generate, with the position of
+// the field or syntax error it stems from when known
+func (g *Generator) Diagnose(err error) Diagnostic {
+	d := Diagnostic{File: g.SourceFile, Message: err.Error()}
+
+	var fieldErr *sourceError
+	var syntaxErrs scanner.ErrorList
+	switch {
+	case errors.As(err, &fieldErr):
+		d.Field = fieldErr.field.Name
+		if fieldErr.field.Pos.IsValid() && g.fset != nil {
+			pos := g.fset.Position(fieldErr.field.Pos)
+			// With --type the field may be declared in another package
+			d.File, d.Line, d.Column = pos.Filename, pos.Line, pos.Column
+		}
+	case errors.As(err, &syntaxErrs) && len(syntaxErrs) > 0:
+		d.Line, d.Column = syntaxErrs[0].Pos.Line, syntaxErrs[0].Pos.Column
+	}
+
+	return d
+}
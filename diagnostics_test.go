@@ -0,0 +1,39 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestJSONDiagnostics(t *testing.T) {
+	dir := writeFiles(t, map[string]string{"source.go": `package main
+
+type ServeArgs struct {
+	Port int    ` + "`cli:\"port,p\"`" + `
+	Path string ` + "`cli:\"path,p\"`" + `
+}
+`})
+	out, err := runCligen(dir, "--format=json", "serve", "Starts an http server")
+	if err == nil {
+		t.Fatalf("generation with duplicate flags succeeded:\n%s", out)
+	}
+	var got Diagnostic
+	if err := json.Unmarshal([]byte(out), &got); err != nil {
+		t.Fatalf("stderr isn't a single JSON object: %v\n%s", err, out)
+	}
+	if got.File != "source.go" || got.Line != 5 || got.Field != "Path" || got.Message == "" {
+		t.Errorf("got %+v, want source.go:5 pointing at Path", got)
+	}
+}
+
+func TestJSONDiagnosticsSyntaxError(t *testing.T) {
+	dir := writeFiles(t, map[string]string{"source.go": "package main\n\ntype ServeArgs struct {\n\tPort int `cli:\"port\"\n}\n"})
+	out, _ := runCligen(dir, "--format=json", "serve", "Starts an http server")
+	var got Diagnostic
+	if err := json.Unmarshal([]byte(out), &got); err != nil {
+		t.Fatalf("stderr isn't a single JSON object: %v\n%s", err, out)
+	}
+	if got.Line != 4 || got.Column == 0 {
+		t.Errorf("got %+v, want the position of the syntax error on line 4", got)
+	}
+}
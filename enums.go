@@ -0,0 +1,154 @@
+package main
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"strconv"
+)
+
+// resolveOptions fills in the options of the fields tagged optionsfrom:
+// from the declaration they name in the source file
+func (g *Generator) resolveOptions(fields []FieldInfo) error {
+	for i, field := range fields {
+		if field.OptionsFrom == "" {
+			continue
+		}
+		if len(field.Options) > 0 {
+			return fieldErrorf(field, "options: and optionsfrom: cannot be combined")
+		}
+
+		values, err := g.enumValues(field.OptionsFrom)
+		if err != nil {
+			return fieldErrorf(field, "%w", err)
+		}
+		g.logf("field %s: options %v from %s", field.Name, values, field.OptionsFrom)
+		fields[i].Options = values
+	}
+
+	return nil
+}
+
+// bindStringType binds a field of a string type declared in the source
+// file, such as type Env string, as a string, since the generated command
+// can't name the type. The string constants declared with the type are the
+// options of the flag unless the tag sets options: or optionsfrom:, so an
+// enum declared as Go constants needs no tag to be checked.
+func (g *Generator) bindStringType(field *FieldInfo) {
+	typeName := field.Type
+	g.logf("field %s: binding %s as string", field.Name, typeName)
+	field.Type = "string"
+	if len(field.Options) > 0 || field.OptionsFrom != "" {
+		return
+	}
+
+	values, err := g.enumConstants(typeName)
+	if err != nil {
+		g.warnf("field %s: the options of %s can't be read, so any value is accepted: %v", field.Name, typeName, err)
+		return
+	}
+	if len(values) > 0 {
+		g.logf("field %s: options %v from the constants of type %s", field.Name, values, typeName)
+		field.Options = values
+	}
+}
+
+// enumValues returns the values of a []string variable declared with a
+// literal of string constants, or of the string constants declared with
+// the given type
+func (g *Generator) enumValues(name string) ([]string, error) {
+	for _, decl := range g.file.Decls {
+		genDecl, ok := decl.(*ast.GenDecl)
+		if !ok || genDecl.Tok != token.VAR {
+			continue
+		}
+
+		for _, spec := range genDecl.Specs {
+			valueSpec := spec.(*ast.ValueSpec)
+			for i, ident := range valueSpec.Names {
+				if ident.Name != name {
+					continue
+				}
+				if i >= len(valueSpec.Values) {
+					return nil, fmt.Errorf("optionsfrom: %s must be initialized with a []string literal", name)
+				}
+				return sliceLiteral(name, valueSpec.Values[i])
+			}
+		}
+	}
+
+	values, err := g.enumConstants(name)
+	if err != nil {
+		return nil, fmt.Errorf("optionsfrom: %w", err)
+	}
+	if len(values) == 0 {
+		return nil, fmt.Errorf("optionsfrom: no []string variable or string constants of type %s found in %s", name, g.SourceFile)
+	}
+	return values, nil
+}
+
+// enumConstants returns the values of the constants declared with the given
+// type, in declaration order. A constant declared without the type, as in
+// B = "b", is an untyped string and isn't counted.
+func (g *Generator) enumConstants(typeName string) ([]string, error) {
+	var values []string
+	for _, decl := range g.file.Decls {
+		genDecl, ok := decl.(*ast.GenDecl)
+		if !ok || genDecl.Tok != token.CONST {
+			continue
+		}
+
+		for _, spec := range genDecl.Specs {
+			valueSpec := spec.(*ast.ValueSpec)
+			if !isIdent(valueSpec.Type, typeName) {
+				continue
+			}
+			for _, value := range valueSpec.Values {
+				s, ok := stringLiteral(value)
+				if !ok {
+					return nil, fmt.Errorf("constants of type %s must be string literals", typeName)
+				}
+				values = append(values, s)
+			}
+		}
+	}
+	return values, nil
+}
+
+// sliceLiteral extracts the strings of a []string composite literal
+func sliceLiteral(name string, expr ast.Expr) ([]string, error) {
+	lit, ok := expr.(*ast.CompositeLit)
+	if !ok {
+		return nil, fmt.Errorf("optionsfrom: %s must be initialized with a []string literal", name)
+	}
+	if array, ok := lit.Type.(*ast.ArrayType); !ok || array.Len != nil || !isIdent(array.Elt, "string") {
+		return nil, fmt.Errorf("optionsfrom: %s must be a []string, got %s", name, types.ExprString(lit.Type))
+	}
+
+	var values []string
+	for _, elt := range lit.Elts {
+		s, ok := stringLiteral(elt)
+		if !ok {
+			return nil, fmt.Errorf("optionsfrom: the values of %s must be string literals", name)
+		}
+		values = append(values, s)
+	}
+	return values, nil
+}
+
+// stringLiteral returns the value of a string literal expression
+func stringLiteral(expr ast.Expr) (string, bool) {
+	lit, ok := expr.(*ast.BasicLit)
+	if !ok || lit.Kind != token.STRING {
+		return "", false
+	}
+	s, err := strconv.Unquote(lit.Value)
+	return s, err == nil
+}
+
+// isIdent reports whether expr is the identifier name
+func isIdent(expr ast.Expr, name string) bool {
+	ident, ok := expr.(*ast.Ident)
+	return ok && ident.Name == name
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestOptionsFrom(t *testing.T) {
+	dir := generate(t, `package main
+
+type Environment string
+
+const (
+	Dev  Environment = "dev"
+	Prod Environment = "prod"
+)
+
+var regions = []string{"eu", "us"}
+
+type DeployArgs struct {
+	Env    string `+"`cli:\"env,optionsfrom:Environment\"`"+`
+	Region string `+"`cli:\"region,optionsfrom:regions\"`"+`
+}
+`, "deploy", "Deploys")
+	app := filepath.Join(dir, "cmd", "deploy")
+	writeHandler(t, app, "deploy", `fmt.Println(args.Env, args.Region)`)
+	bin := buildCommand(t, app)
+
+	if out, err := runCommand(bin, "--env=prod", "--region=us"); err != nil || out != "prod us\n" {
+		t.Errorf("--env=prod --region=us gave %q, %v", out, err)
+	}
+	for _, arg := range []string{"--env=qa", "--region=asia"} {
+		if out, err := runCommand(bin, arg); err == nil || !strings.Contains(out, "must be one of") {
+			t.Errorf("%s gave %v, want it rejected:\n%s", arg, err, out)
+		}
+	}
+	generateFails(t, "package main\n\ntype DeployArgs struct {\n\tEnv string `cli:\"env,optionsfrom:Missing\"`\n}\n", "optionsfrom: no []string variable or string constants of type Missing", "deploy", "Deploys")
+}
+
+func TestEnumTypes(t *testing.T) {
+	dir := generate(t, `package main
+
+type DeployArgs struct {
+	Env string `+"`cli:\"env,e,options:dev|staging|prod,env:APP_ENV\"`"+`
+}
+`, "--enum-types", "deploy", "Deploys")
+	app := filepath.Join(dir, "cmd", "deploy")
+	writeHandler(t, app, "deploy", `fmt.Println(args.Env)`)
+	bin := buildCommand(t, app)
+
+	if out, err := runCommand(bin, "-e", "staging"); err != nil || out != "staging\n" {
+		t.Errorf("-e staging gave %q, %v", out, err)
+	}
+	want := `invalid argument "x" for "-e, --env" flag: must be one of: dev, staging, prod`
+	if out, err := runCommand(bin, "--env=x"); err == nil || !strings.Contains(out, want) {
+		t.Errorf("--env=x gave %v, want %q:\n%s", err, want, out)
+	}
+	if out, err := runCommandEnv(bin, []string{"APP_ENV=x"}); err == nil || !strings.Contains(out, "must be one of: dev, staging, prod") {
+		t.Errorf("$APP_ENV=x gave %v, want it rejected:\n%s", err, out)
+	}
+	if out, _ := runCommand(bin, "--help"); !strings.Contains(out, "--env dev|staging|prod") {
+		t.Errorf("--help doesn't show the options as the value type:\n%s", out)
+	}
+}
+
+func TestNamedStringTypeOptions(t *testing.T) {
+	dir := generate(t, `package main
+
+type Environment string
+
+const (
+	Dev     Environment = "dev"
+	Staging Environment = "staging"
+	Prod    Environment = "prod"
+)
+
+type Region string
+
+type DeployArgs struct {
+	Env    Environment `+"`cli:\"env\"`"+`
+	Stage  Environment `+"`cli:\"stage,options:dev|prod\"`"+`
+	Region Region      `+"`cli:\"region\"`"+`
+}
+`, "deploy", "Deploys")
+	app := filepath.Join(dir, "cmd", "deploy")
+	writeHandler(t, app, "deploy", `fmt.Println(args.Env, args.Stage, args.Region)`)
+	bin := buildCommand(t, app)
+
+	if out, err := runCommand(bin, "--env=staging", "--region=anywhere"); err != nil || out != "staging  anywhere\n" {
+		t.Errorf("--env=staging --region=anywhere gave %q, %v", out, err)
+	}
+	for _, tt := range []struct{ arg, want string }{
+		{"--env=qa", "--env must be one of: dev, staging, prod"},
+		{"--stage=staging", "--stage must be one of: dev, prod"},
+	} {
+		if out, err := runCommand(bin, tt.arg); err == nil || !strings.Contains(out, tt.want) {
+			t.Errorf("%s gave %v, want %q:\n%s", tt.arg, err, tt.want, out)
+		}
+	}
+}
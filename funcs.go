@@ -0,0 +1,109 @@
+package main
+
+import (
+	"fmt"
+	"go/ast"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// generateFromFunc generates the CLI from the parameters of a function
+// declaration instead of an args struct. Each parameter becomes a flag
+// named after the parameter. Since parameters cannot carry struct tags,
+// modifiers come from //cligen:flag comments in the function's doc:
+//
+//	//cligen:flag port p default:8080 usage:"Port to listen on"
+//	func Serve(port int, env string) error
+//
+// A trailing variadic parameter (args ...string) becomes a variadic
+// positional argument.
+func (g *Generator) generateFromFunc(node *ast.File) error {
+	var fn *ast.FuncDecl
+	for _, decl := range node.Decls {
+		if funcDecl, ok := decl.(*ast.FuncDecl); ok && funcDecl.Recv == nil && funcDecl.Name.Name == g.Func {
+			fn = funcDecl
+			break
+		}
+	}
+	if fn == nil {
+		return fmt.Errorf("could not find function %s", g.Func)
+	}
+	g.logf("matched function %s", fn.Name.Name)
+	start := time.Now()
+
+	if g.Help == "" && fn.Doc != nil {
+		g.Help = docSummary(fn.Doc, fn.Name.Name)
+	}
+
+	modifiers := funcFlagModifiers(fn.Doc)
+
+	var fields []FieldInfo
+	for _, param := range fn.Type.Params.List {
+		fieldType := g.getTypeString(param.Type)
+		_, variadic := param.Type.(*ast.Ellipsis)
+
+		for _, name := range param.Names {
+			field := FieldInfo{
+				Name:       exportedName(name.Name),
+				Type:       fieldType,
+				CLIName:    g.flagName(name.Name),
+				Positional: variadic,
+				Variadic:   variadic,
+				Pos:        name.Pos(),
+			}
+			g.applyModifiers(&field, modifiers[name.Name])
+
+			g.logf("parameter %s: --%s (%s)", name.Name, field.CLIName, field.Type)
+			fields = append(fields, field)
+		}
+	}
+
+	if err := g.resolveOptions(fields); err != nil {
+		return fmt.Errorf("failed to parse function parameters: %w", err)
+	}
+	fields, err := g.withPreset(fields)
+	if err != nil {
+		return fmt.Errorf("failed to parse function parameters: %w", err)
+	}
+	fields = orderFields(g.withOutputFormat(fields))
+	if err := g.validateFields(fields); err != nil {
+		return fmt.Errorf("failed to parse function parameters: %w", err)
+	}
+	g.trace("fields", g.Command, start)
+
+	return g.generateCLICode(fn.Name.Name, fields)
+}
+
+// funcFlagModifiers reads the //cligen:flag <param> <modifiers...> comments
+// of a function, keyed by parameter name. Modifiers are separated by
+// spaces; quote a modifier to include spaces in it.
+func funcFlagModifiers(doc *ast.CommentGroup) map[string][]string {
+	modifiers := make(map[string][]string)
+	if doc == nil {
+		return modifiers
+	}
+
+	for _, comment := range doc.List {
+		line, ok := strings.CutPrefix(comment.Text, "//cligen:flag ")
+		if !ok {
+			continue
+		}
+
+		args := splitArgs(line)
+		if len(args) == 0 {
+			continue
+		}
+		modifiers[args[0]] = append(modifiers[args[0]], args[1:]...)
+	}
+
+	return modifiers
+}
+
+// exportedName upper-cases the first letter of a parameter name so it can
+// be used as a field of the generated command struct
+func exportedName(name string) string {
+	runes := []rune(name)
+	runes[0] = unicode.ToUpper(runes[0])
+	return string(runes)
+}
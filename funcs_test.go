@@ -0,0 +1,34 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestGenerateFromFunc(t *testing.T) {
+	dir := generate(t, `package main
+
+// Serve starts an HTTP server.
+//
+//cligen:flag port p default:8080 usage:"Port to listen on"
+//cligen:flag env e required options:dev|prod
+func Serve(port int, env string, files ...string) error { return nil }
+`, "--func=Serve")
+	app := filepath.Join(dir, "cmd", "serve")
+	writeHandler(t, app, "serve", `fmt.Println(args.Port, args.Env, args.Files)`)
+	bin := buildCommand(t, app)
+
+	if out, err := runCommand(bin, "-e", "dev", "a", "b"); err != nil || out != "8080 dev [a b]\n" {
+		t.Errorf("serve -e dev a b gave %q, %v, want \"8080 dev [a b]\\n\"", out, err)
+	}
+	out, _ := runCommand(bin, "--help")
+	for _, want := range []string{"Starts an HTTP server.", "Port to listen on", "-e, --env"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("--help lacks %q:\n%s", want, out)
+		}
+	}
+	if out, err := runCommand(bin); err == nil || !strings.Contains(out, "--env is required") {
+		t.Errorf("serve without --env gave %v, want a missing --env:\n%s", err, out)
+	}
+}
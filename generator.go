@@ -1,15 +1,27 @@
 package main
 
 import (
+	"bytes"
+	"cmp"
 	"embed"
+	"errors"
 	"fmt"
 	"go/ast"
+	"go/format"
 	"go/parser"
 	"go/token"
+	"go/types"
+	"io"
+	"log"
 	"os"
+	"path/filepath"
 	"reflect"
+	"slices"
+	"strconv"
 	"strings"
 	"text/template"
+	"time"
+	"unicode"
 
 	"golang.org/x/text/cases"
 	"golang.org/x/text/language"
@@ -24,6 +36,139 @@ type Generator struct {
 	Command    string
 	Help       string
 	OutputFile string
+	Program    string
+	Backend    string
+	// Backends lists several backends to generate side by side, each into
+	// a subdirectory of the output directory named after it
+	Backends []string
+	Func     string
+	Verbose  bool
+	Stringer bool
+	// Strict turns warnings about the input into errors
+	Strict bool
+	// WarnTagKeys warns about struct tag keys one edit away from cli, which
+	// are likely typos leaving the field with the default naming
+	WarnTagKeys bool
+	// NoMain leaves out func main, for programs wiring several commands
+	// in a main of their own
+	NoMain bool
+	// EnumTypes registers flags with options through a flag.Value that
+	// rejects other values while parsing
+	EnumTypes bool
+	// Insert merges a command into an existing generated file, replacing
+	// only the block between its sentinel comments
+	Insert bool
+	// NoFormat writes the generated code as rendered instead of running
+	// it through gofmt
+	NoFormat bool
+	// Header is a block of comments, such as a license, prepended to the
+	// generated files. HeaderAfter places it below the generated-code
+	// marker instead of above it.
+	Header      string
+	HeaderAfter bool
+	// TrimSuffixes are stripped from struct names to infer command names,
+	// in order. Empty means CLIArgs and Args.
+	TrimSuffixes []string
+	// OutputFormat adds an --output flag and a Render helper
+	OutputFormat bool
+	// Preset names a set of flags added to every command, see presets
+	Preset string
+	// Interactive asks for missing required flags when stdin is a terminal
+	Interactive bool
+	// StructTags lists the struct tag keys, such as json and yaml, given to
+	// the fields of the generated command struct
+	StructTags []string
+	// Color adds a --color flag and prints errors in red on terminals
+	Color bool
+	// Completion lets the generated program write its shell completion
+	// scripts with a hidden --generate-completion flag
+	Completion bool
+	// WriteConfig adds a --write-config flag writing a YAML file with every
+	// flag set to its default
+	WriteConfig bool
+	// SortFlags lists flags alphabetically in the help instead of in
+	// declaration order
+	SortFlags bool
+	// HelpWidth wraps the flag usage at the given column when set
+	HelpWidth int
+	// ReadValidateTag translates the rules of the validate tags read by
+	// go-playground/validator into required flags, options and bounds
+	ReadValidateTag bool
+	// DefaultStyle writes the defaults into the flag usage as (default: X)
+	// when inline, and leaves them out when none. Empty leaves them to the
+	// flag package, which appends (default X) to flags with a non-zero one.
+	DefaultStyle string
+	// WindowsFlags accepts /name:value and /name for --name=value and
+	// --name, see --style=windows
+	WindowsFlags bool
+	// Perm is the exact mode of the generated files. Zero writes them with
+	// 0644 less the umask.
+	Perm os.FileMode
+	// PrintOutputs runs the generation without writing anything, recording
+	// the paths of the files it would regenerate in Outputs instead
+	PrintOutputs bool
+	Outputs      []string
+	// LocalFlags registers the flags on a FlagSet owned by the command
+	// instead of the global CommandLine
+	LocalFlags bool
+	// ModulePath is the module of the source file, detected from go.mod
+	// when not set explicitly
+	ModulePath string
+	// Invocation is the cligen command line recorded in generated files
+	Invocation string
+	// NameStyle is how flag names are derived from field names: kebab
+	// (the default when empty), snake or camel
+	NameStyle string
+	// Trace logs how long each phase of the generation takes, to find
+	// where a slow run spends its time
+	Trace bool
+	// DebugAST prints the type declarations of the source and the fields of
+	// each matched struct, as parsed, to stderr
+	DebugAST bool
+	// BinName is the program name shown in the usage line and the --help
+	// hints, in place of the command name
+	BinName string
+	// Fields selects the fields of the args struct the command gets flags
+	// for, by field or flag name. Empty selects all of them.
+	Fields []string
+	// Type names the args struct as <import path>.<name> to load it from
+	// another package instead of the source file
+	Type string
+	// Implements names an interface as [<import path>.]<name> that the
+	// generated commands are asserted to implement
+	Implements string
+	// Spec describes the commands in place of Go source, see --spec. The
+	// SourceFile is then the spec file.
+	Spec *Spec
+	// More lists the further commands of an invocation passing several
+	// --command flags, generated from the same parsed source
+	More []invocation
+
+	// warnings recorded in strict mode, see warnf
+	warnings []string
+
+	// src is the source code when given directly, see ParseCommand. The
+	// source file is read when it is nil.
+	src []byte
+	// file is the parsed source file, and fset holds its positions
+	file *ast.File
+	fset *token.FileSet
+	// structs indexes every struct type declared in the source file by name
+	structs map[string]*ast.StructType
+	// docs holds the doc comment of each struct type by name
+	docs map[string]*ast.CommentGroup
+	// generics records the struct types declared with type parameters
+	generics map[string]bool
+	// stringTypes records the types declared as a string, as in type Env
+	// string, which are bound as strings
+	stringTypes map[string]bool
+	// prefixes maps the dotted path of each nested struct of the command
+	// being parsed to the prefix of its flag names
+	prefixes map[string]string
+	// moduleRoot is the directory containing the source module's go.mod
+	moduleRoot string
+	// implemented is the resolved Implements interface
+	implemented *implemented
 }
 
 // FieldInfo represents a CLI field with its metadata
@@ -33,65 +178,776 @@ type FieldInfo struct {
 	CLIName      string
 	ShortFlag    string
 	DefaultValue string
+	DefaultFunc  string // Function called for the default when the flag isn't given
 	Required     bool
 	Options      []string
-	Help         string
-	Usage        string // New field for per-option help
+	OptionsFrom  string   // Declaration in the source file listing the options
+	Help         string   // Help from description: or help:, used without usage:
+	Usage        string   // New field for per-option help
+	Group        string   // Help section from a //cligen:section comment
+	Positional   bool     // Bound to a positional argument instead of a flag
+	Variadic     bool     // Positional capturing all remaining arguments
+	Validate     bool     // Calls a user-written validation hook after parsing
+	Secret       bool     // Value is redacted when the command is printed
+	Passthrough  bool     // Captures the arguments after a -- terminator
+	Layout       string   // time.Parse layout of a time.Time field
+	Aliases      []string // Additional long names registering the same flag
+	Count        bool     // Counts how often the flag is given, as in -vvv
+	Max          string   // Upper bound a count is clamped to after parsing
+	Env          string   // Environment variable read when the flag isn't given
+	Enum         bool     // Options are checked by a flag.Value while parsing
+	Placeholder  string   // Name of the flag's value in the help, as in --file FILE
+	HideDefault  bool     // Default is left out of the help
+	TypeOverride string   // Type named by type:, bound instead of the declared one
+	NoShort      bool     // Flag is registered with its long name only
+	Stdio        bool     // Path where - means stdin or stdout, see stdioFields
+	Minimum      string   // Lower bound from the validate tag, see Bounds
+	Maximum      string   // Upper bound from the validate tag, see Bounds
+	SkipZero     bool     // Bounds leave the zero value unchecked, as omitempty
+	Order        int      // Sort weight from order:, see orderFields
+	Human        string   // size or count for an integer taking 2MB or 10k
+
+	// Optional lists the paths of the pointer structs the field is nested
+	// in, outermost first, which stay nil unless one of their flags is given
+	Optional []string
+
+	// RequiredMessage is printed instead of the default error when the
+	// field is required and missing
+	RequiredMessage string
+
+	// Pos is the position of the field in the source file, for diagnostics
+	Pos token.Pos
+}
+
+// Description returns the help text of the flag. usage: takes precedence
+// over description: and help:.
+func (f FieldInfo) Description() string {
+	if f.Usage != "" {
+		return f.Usage
+	}
+	return f.Help
+}
+
+// InlineDefault returns the default --default-style=inline writes into the
+// flag's help: the default: or the default:func: call, and otherwise the
+// zero value of booleans and numbers, which the flag packages leave out.
+// It is "" when the help shows no default.
+func (f FieldInfo) InlineDefault() string {
+	zero := f.Binding().ZeroText
+	switch {
+	case f.HideDefault || f.Required:
+		return ""
+	case f.DefaultFunc != "":
+		return f.DefaultFunc + "()"
+	case f.DefaultValue != "":
+		return f.DefaultValue
+	case strings.HasPrefix(f.Type, "[]"):
+		return ""
+	}
+	return zero
+}
+
+// PlaceholderUsage marks the placeholder in the flag's help with backticks,
+// which the flag packages print as the value name instead of the type. The
+// first occurrence of the placeholder as a word is marked, or it is
+// appended when the help doesn't mention it.
+func (f FieldInfo) PlaceholderUsage(help string) string {
+	if f.Placeholder == "" {
+		return help
+	}
+	for i := strings.Index(help, f.Placeholder); i >= 0; {
+		end := i + len(f.Placeholder)
+		if (i == 0 || !isWordByte(help[i-1])) && (end == len(help) || !isWordByte(help[end])) {
+			return help[:i] + "`" + f.Placeholder + "`" + help[end:]
+		}
+		next := strings.Index(help[end:], f.Placeholder)
+		if next < 0 {
+			break
+		}
+		i = end + next
+	}
+	return help + " (`" + f.Placeholder + "`)"
+}
+
+// isWordByte reports whether b can be part of a placeholder word
+func isWordByte(b byte) bool {
+	return b == '_' || b == '-' || ('a' <= b && b <= 'z') || ('A' <= b && b <= 'Z') || ('0' <= b && b <= '9')
+}
+
+// SectionCheck returns the condition that the optional sections holding
+// the field are allocated, or "" when it isn't in one
+func (f FieldInfo) SectionCheck() string {
+	var checks []string
+	for _, path := range f.Optional {
+		checks = append(checks, "c."+path+" != nil")
+	}
+	return strings.Join(checks, " && ")
+}
+
+// hasRequired reports whether any flag must be given on the command line
+func hasRequired(fields []FieldInfo) bool {
+	for _, field := range fields {
+		if field.Required && field.IsFlag() {
+			return true
+		}
+	}
+	return false
+}
+
+// hasOptions reports whether any field needs its value checked against
+// its options after parsing. Enum flags are checked while parsing, except
+// for the values returned by a default:func:.
+func hasOptions(fields []FieldInfo) bool {
+	for _, field := range fields {
+		if len(field.Options) > 0 && (!field.Enum || field.DefaultFunc != "") {
+			return true
+		}
+	}
+	return false
+}
+
+// hasEnums reports whether any flag is bound through an enum value
+func hasEnums(fields []FieldInfo) bool {
+	for _, field := range fields {
+		if field.Enum {
+			return true
+		}
+	}
+	return false
+}
+
+// withEnums marks the flags with options to be registered through an enum
+// value for --enum-types
+func withEnums(fields []FieldInfo) []FieldInfo {
+	marked := make([]FieldInfo, len(fields))
+	for i, field := range fields {
+		field.Enum = len(field.Options) > 0 && field.IsFlag()
+		marked[i] = field
+	}
+	return marked
+}
+
+// hasDefaultFuncs reports whether any flag takes its default from a function
+func hasDefaultFuncs(fields []FieldInfo) bool {
+	for _, field := range fields {
+		if field.DefaultFunc != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// hasMaxes reports whether any count is clamped to a maximum
+func hasMaxes(fields []FieldInfo) bool {
+	for _, field := range fields {
+		if field.Max != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// withEnvPrefix binds the flags without an explicit env: to the variable
+// named after the flag with the prefix prepended (port -> APP_PORT)
+func withEnvPrefix(fields []FieldInfo, prefix string) []FieldInfo {
+	if prefix == "" {
+		return fields
+	}
+
+	bound := make([]FieldInfo, len(fields))
+	for i, field := range fields {
+		if field.Env == "" && field.IsFlag() {
+			// kebabCase splits camel-case names too, so maxRetries is MAX_RETRIES
+			field.Env = prefix + strings.ToUpper(strings.ReplaceAll(kebabCase(field.CLIName), "-", "_"))
+		}
+		bound[i] = field
+	}
+	return bound
+}
+
+// hasEnv reports whether any flag falls back to an environment variable
+func hasEnv(fields []FieldInfo) bool {
+	for _, field := range fields {
+		if field.Env != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// hasRequiredArgs reports whether any positional argument must be given
+func hasRequiredArgs(fields []FieldInfo) bool {
+	for _, field := range fields {
+		if field.Required && field.Positional {
+			return true
+		}
+	}
+	return false
+}
+
+// hasRequiredEnv reports whether any required flag can be given through
+// the environment instead
+func hasRequiredEnv(fields []FieldInfo) bool {
+	for _, field := range fields {
+		if field.Required && field.Env != "" && field.IsFlag() {
+			return true
+		}
+	}
+	return false
+}
+
+// hasAliases reports whether any flag has alternative names
+func hasAliases(fields []FieldInfo) bool {
+	for _, field := range fields {
+		if len(field.Aliases) > 0 && field.IsFlag() {
+			return true
+		}
+	}
+	return false
+}
+
+// hasTime reports whether any field is a time.Time flag
+func hasTime(fields []FieldInfo) bool {
+	for _, field := range fields {
+		if field.Type == "time.Time" {
+			return true
+		}
+	}
+	return false
+}
+
+// IsFlag reports whether the field is bound to a flag rather than to
+// command line arguments
+func (f FieldInfo) IsFlag() bool {
+	return !f.Positional && !f.Passthrough
+}
+
+// passthrough returns the field capturing the arguments after --, if any
+func passthrough(fields []FieldInfo) *FieldInfo {
+	for i := range fields {
+		if fields[i].Passthrough {
+			return &fields[i]
+		}
+	}
+	return nil
+}
+
+// positionals returns the fields bound to positional arguments in order
+func positionals(fields []FieldInfo) []FieldInfo {
+	var result []FieldInfo
+	for _, field := range fields {
+		if field.Positional {
+			result = append(result, field)
+		}
+	}
+	return result
+}
+
+// argsUsage renders the positional arguments for the usage line, e.g.
+// " <src> [dst...]"
+func argsUsage(fields []FieldInfo) string {
+	var b strings.Builder
+	for _, field := range positionals(fields) {
+		name := field.CLIName
+		if field.Variadic {
+			name += "..."
+		}
+		if field.Required {
+			fmt.Fprintf(&b, " <%s>", name)
+		} else {
+			fmt.Fprintf(&b, " [%s]", name)
+		}
+	}
+	if field := passthrough(fields); field != nil {
+		fmt.Fprintf(&b, " [-- %s...]", field.CLIName)
+	}
+	return b.String()
+}
+
+// FlagGroup is a help section listing the flags declared under it
+type FlagGroup struct {
+	Name  string
+	Flags []string
+}
+
+// buildGroups groups the flags by help section in declaration order. It
+// returns nil when no section markers were used.
+func buildGroups(fields []FieldInfo) []FlagGroup {
+	var groups []FlagGroup
+	sectioned := false
+
+	for _, field := range fields {
+		if !field.IsFlag() {
+			continue
+		}
+		if field.Group != "" {
+			sectioned = true
+		}
+
+		idx := -1
+		for i := range groups {
+			if groups[i].Name == field.Group {
+				idx = i
+				break
+			}
+		}
+		if idx < 0 {
+			groups = append(groups, FlagGroup{Name: field.Group})
+			idx = len(groups) - 1
+		}
+		groups[idx].Flags = append(groups[idx].Flags, field.CLIName)
+	}
+
+	if !sectioned {
+		return nil
+	}
+	return groups
+}
+
+// OptionalSection is a nested struct behind a pointer, allocated while
+// parsing and reset to nil unless one of its flags was given
+type OptionalSection struct {
+	Path string
+	// Guard checks that the sections holding this one are allocated
+	Guard string
+	// Flags are the flags of the section, including nested sections
+	Flags []FieldInfo
+}
+
+// buildOptionals lists the optional sections holding flags, outer sections
+// before the ones nested in them
+func buildOptionals(fields []FieldInfo) []OptionalSection {
+	var sections []OptionalSection
+	index := map[string]int{}
+	for _, field := range fields {
+		if !field.IsFlag() {
+			continue
+		}
+		for i, path := range field.Optional {
+			if _, ok := index[path]; !ok {
+				index[path] = len(sections)
+				parent := FieldInfo{Optional: field.Optional[:i]}
+				sections = append(sections, OptionalSection{Path: path, Guard: parent.SectionCheck()})
+			}
+			sections[index[path]].Flags = append(sections[index[path]].Flags, field)
+		}
+	}
+	return sections
+}
+
+// StructField is a field of the generated command struct. Flattened nested
+// structs are rendered as anonymous struct fields holding their own fields.
+type StructField struct {
+	Name   string
+	Type   string
+	Fields []StructField
+	// Pointer is set for optional sections, held as a pointer to the struct
+	Pointer bool
+	// Tag is the struct tag from --struct-tags, without the backquotes
+	Tag string
+}
+
+// buildStructFields rebuilds the nested command struct layout from the
+// dotted field paths of the flattened fields
+func (g *Generator) buildStructFields(fields []FieldInfo) []StructField {
+	var result []StructField
+
+	for _, field := range fields {
+		path := strings.Split(field.Name, ".")
+		level := &result
+		for i, name := range path {
+			if i == len(path)-1 {
+				*level = append(*level, StructField{Name: name, Type: field.Type, Tag: g.structTag(field.Name, field.CLIName)})
+				break
+			}
+
+			idx := -1
+			for j := range *level {
+				if (*level)[j].Name == name {
+					idx = j
+					break
+				}
+			}
+			if idx < 0 {
+				nested := strings.Join(path[:i+1], ".")
+				pointer := slices.Contains(field.Optional, nested)
+				*level = append(*level, StructField{Name: name, Type: "struct", Pointer: pointer, Tag: g.structTag(nested, g.prefixes[nested])})
+				idx = len(*level) - 1
+			}
+			level = &(*level)[idx].Fields
+		}
+	}
+
+	return result
+}
+
+// structTag renders the --struct-tags of the struct field at the dotted
+// path, keyed by its flag name. Inside a nested struct the key drops the
+// prefix the struct adds to its flags, so the tags describe the same nested
+// layout as the struct: --tls-cert becomes "cert" inside "tls".
+func (g *Generator) structTag(path, flag string) string {
+	if len(g.StructTags) == 0 {
+		return ""
+	}
+
+	key := g.tagKey(path, flag)
+	var tags []string
+	for _, name := range g.StructTags {
+		tags = append(tags, fmt.Sprintf("%s:%q", name, key))
+	}
+	return strings.Join(tags, " ")
+}
+
+// tagKey returns the key of the struct field at the dotted path in the
+// --struct-tags and the --write-config file
+func (g *Generator) tagKey(path, flag string) string {
+	key := flag
+	if i := strings.LastIndex(path, "."); i >= 0 {
+		key = g.trimFlagPrefix(g.prefixes[path[:i]], flag)
+	}
+	if key == "" {
+		key = g.flagName(path[strings.LastIndex(path, ".")+1:])
+	}
+	return key
 }
 
 // Generate parses the source file and generates CLI code
 func (g *Generator) Generate() error {
-	// Parse the Go source file
-	fset := token.NewFileSet()
-	node, err := parser.ParseFile(fset, g.SourceFile, nil, parser.ParseComments)
+	defer g.trace("generate", g.SourceFile, time.Now())
+
+	node, err := g.parseSource()
 	if err != nil {
-		return fmt.Errorf("failed to parse source file: %w", err)
+		return err
 	}
+	g.dumpTypes(node)
 
-	// Find the struct that corresponds to our command
-	var targetStruct *ast.StructType
-	var structName string
+	if err := g.resolveModule(); err != nil {
+		return fmt.Errorf("failed to resolve module: %w", err)
+	}
 
-	ast.Inspect(node, func(n ast.Node) bool {
-		if genDecl, ok := n.(*ast.GenDecl); ok && genDecl.Tok == token.TYPE {
-			for _, spec := range genDecl.Specs {
-				if typeSpec, ok := spec.(*ast.TypeSpec); ok {
-					if structType, ok := typeSpec.Type.(*ast.StructType); ok {
-						// Check if this struct has the right naming pattern
-						name := typeSpec.Name.Name
-						if strings.Contains(strings.ToLower(name), strings.ToLower(g.Command)) &&
-							strings.Contains(strings.ToLower(name), "args") {
-							targetStruct = structType
-							structName = name
-							return false
-						}
-					}
-				}
-			}
+	if err := g.resolveImplements(); err != nil {
+		return err
+	}
+
+	if len(g.Backends) == 0 {
+		return g.generate(node)
+	}
+
+	command, help, output, fields, more := g.Command, g.Help, g.OutputFile, g.Fields, g.More
+	for _, backend := range g.Backends {
+		g.Backend = backend
+		g.Command, g.Help, g.OutputFile, g.Fields = command, help, backendOutput(output, backend), fields
+		g.More = nil
+		for _, inv := range more {
+			inv.OutputFile = backendOutput(inv.OutputFile, backend)
+			g.More = append(g.More, inv)
 		}
-		return true
-	})
 
+		if err := g.generate(node); err != nil {
+			return fmt.Errorf("%s backend: %w", backend, err)
+		}
+	}
+
+	return nil
+}
+
+// backendOutput moves an output file into a subdirectory named after the
+// backend, so that backends generated side by side don't collide
+func backendOutput(path, backend string) string {
+	return filepath.Join(filepath.Dir(path), backend, filepath.Base(path))
+}
+
+// generate generates the code for g.Backend from the parsed source file
+func (g *Generator) generate(node *ast.File) error {
+	if g.Program != "" {
+		return g.generateSubcommands(node)
+	}
+
+	if g.Func != "" {
+		return g.generateFromFunc(node)
+	}
+
+	if err := g.generateCommand(node); err != nil {
+		return err
+	}
+	for _, inv := range g.More {
+		g.Command, g.Help, g.OutputFile, g.Fields = inv.Command, inv.Help, inv.OutputFile, inv.Fields
+		if err := g.generateCommand(node); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// generateCommand generates the CLI for g.Command from its args struct
+func (g *Generator) generateCommand(node *ast.File) error {
+	structName, fields, err := g.parseCommand(node)
+	if err != nil {
+		return err
+	}
+
+	// Generate the CLI code
+	return g.generateCLICode(structName, fields)
+}
+
+// ParseCommand parses the args struct of a command from Go source and its
+// cli tags, exactly as cligen does before generating code, without writing
+// anything. It returns the struct's name and its flattened fields, for tools
+// that document or check commands, or render them with templates of their
+// own.
+func ParseCommand(src []byte, command string) (structName string, fields []FieldInfo, err error) {
+	g := &Generator{
+		SourceFile: "source.go",
+		Command:    command,
+		Backend:    "pflag",
+		src:        src,
+	}
+
+	node, err := g.parseSource()
+	if err != nil {
+		return "", nil, err
+	}
+	return g.parseCommand(node)
+}
+
+// parseCommand finds the struct of g.Command and parses its fields
+func (g *Generator) parseCommand(node *ast.File) (string, []FieldInfo, error) {
+	if g.Spec != nil {
+		return g.specCommand()
+	}
+
+	structName, targetStruct := g.findStruct(node)
 	if targetStruct == nil {
-		return fmt.Errorf("could not find struct for command %s", g.Command)
+		return "", nil, fmt.Errorf("could not find struct for command %s", g.Command)
+	}
+	if err := g.checkGeneric(structName); err != nil {
+		return "", nil, err
 	}
+	g.dumpStruct(g.Command, structName, targetStruct)
 
 	// Parse struct fields and their tags
+	start := time.Now()
 	fields, err := g.parseStructFields(targetStruct)
 	if err != nil {
-		return fmt.Errorf("failed to parse struct fields: %w", err)
+		return "", nil, fmt.Errorf("failed to parse struct fields: %w", err)
 	}
+	g.trace("fields", g.Command, start)
 
-	// Generate the CLI code
-	return g.generateCLICode(structName, fields)
+	return structName, fields, nil
+}
+
+// findStruct finds the args struct of g.Command, either named by --type or
+// the first struct whose name contains the command and "args" or one of the
+// command suffixes. The struct is nil when there is none.
+func (g *Generator) findStruct(node *ast.File) (structName string, targetStruct *ast.StructType) {
+	defer g.trace("discovery", g.Command, time.Now())
+
+	if g.Type != "" {
+		// The struct is named explicitly, see loadType
+		_, structName = splitTypeName(g.Type)
+		targetStruct = g.structs[structName]
+		g.logf("matched struct %s: named by --type", structName)
+	} else {
+		ast.Inspect(node, func(n ast.Node) bool {
+			if typeSpec, ok := n.(*ast.TypeSpec); ok {
+				if structType, ok := typeSpec.Type.(*ast.StructType); ok {
+					// Check if this struct has the right naming pattern
+					name := typeSpec.Name.Name
+					_, suffixed := g.trimSuffix(name)
+					if strings.Contains(strings.ToLower(name), strings.ToLower(g.Command)) &&
+						(strings.Contains(strings.ToLower(name), "args") || suffixed) {
+						targetStruct = structType
+						structName = name
+						g.logf("matched struct %s: name contains %q and \"args\" or a command suffix", name, g.Command)
+						return false
+					}
+				}
+			}
+			return targetStruct == nil
+		})
+	}
+
+	return structName, targetStruct
+}
+
+// checkGeneric rejects an args struct with type parameters, whose fields
+// have no concrete type to bind a flag to
+func (g *Generator) checkGeneric(structName string) error {
+	if g.generics[structName] {
+		return fmt.Errorf("struct %s: generic argument structs are not supported, declare the args struct with concrete field types", structName)
+	}
+	return nil
+}
+
+// parseSource parses the source file and indexes the struct types it declares
+func (g *Generator) parseSource() (*ast.File, error) {
+	defer g.trace("parse", g.SourceFile, time.Now())
+
+	if g.Type != "" {
+		return g.loadType()
+	}
+	if g.Spec != nil {
+		// There is no source to parse, the fields come from the spec
+		g.file = &ast.File{Name: ast.NewIdent("main")}
+		g.indexStructs(g.file)
+		return g.file, nil
+	}
+
+	// Parse the Go source file
+	// A nil []byte would be parsed as empty source rather than reading the
+	// file, so only pass it on when set
+	var src any
+	if g.src != nil {
+		src = g.src
+	}
+	fset := token.NewFileSet()
+	node, err := parser.ParseFile(fset, g.SourceFile, src, parser.ParseComments)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse source file: %w", err)
+	}
+
+	g.file, g.fset = node, fset
+	g.indexStructs(node)
+	return node, nil
+}
+
+// indexStructs records the struct types declared in a file and their doc
+// comments
+func (g *Generator) indexStructs(node *ast.File) {
+	g.structs = make(map[string]*ast.StructType)
+	g.docs = make(map[string]*ast.CommentGroup)
+	g.generics = make(map[string]bool)
+	g.stringTypes = make(map[string]bool)
+	ast.Inspect(node, func(n ast.Node) bool {
+		genDecl, ok := n.(*ast.GenDecl)
+		if !ok || genDecl.Tok != token.TYPE {
+			return true
+		}
+
+		for _, spec := range genDecl.Specs {
+			typeSpec, ok := spec.(*ast.TypeSpec)
+			if !ok {
+				continue
+			}
+			if isIdent(typeSpec.Type, "string") && typeSpec.Assign == 0 && typeSpec.TypeParams == nil {
+				g.stringTypes[typeSpec.Name.Name] = true
+			}
+			structType, ok := typeSpec.Type.(*ast.StructType)
+			if !ok {
+				continue
+			}
+
+			name := typeSpec.Name.Name
+			g.structs[name] = structType
+			g.generics[name] = typeSpec.TypeParams != nil
+
+			// A lone type declaration carries its doc comment on the GenDecl
+			doc := typeSpec.Doc
+			if doc == nil && len(genDecl.Specs) == 1 {
+				doc = genDecl.Doc
+			}
+			g.docs[name] = doc
+		}
+		return true
+	})
 }
 
 // parseStructFields extracts field information from struct fields
 func (g *Generator) parseStructFields(structType *ast.StructType) ([]FieldInfo, error) {
-	var fields []FieldInfo
+	g.prefixes = make(map[string]string)
+	fields, err := g.collectFields(structType, "", "", map[*ast.StructType]bool{})
+	if err != nil {
+		return nil, err
+	}
+	if fields, err = g.selectFields(fields); err != nil {
+		return nil, err
+	}
+	if err := g.resolveOptions(fields); err != nil {
+		return nil, err
+	}
+	if fields, err = g.withPreset(fields); err != nil {
+		return nil, err
+	}
+	fields = orderFields(g.withOutputFormat(fields))
+
+	if err := g.validateFields(fields); err != nil {
+		return nil, err
+	}
+
+	return fields, nil
+}
+
+// orderFields sorts the fields by their order: weight, keeping the order
+// they were collected in for equal weights. Fields without one weigh 0, so
+// negative weights move a field before them and positive ones after.
+func orderFields(fields []FieldInfo) []FieldInfo {
+	slices.SortStableFunc(fields, func(a, b FieldInfo) int {
+		return cmp.Compare(a.Order, b.Order)
+	})
+	return fields
+}
+
+// selectFields keeps the fields named by --fields, in declaration order.
+// A field is named by its flag name or its field name, dotted inside nested
+// structs as in TLS.Cert, and naming a nested struct selects all of its
+// fields.
+func (g *Generator) selectFields(fields []FieldInfo) ([]FieldInfo, error) {
+	if len(g.Fields) == 0 {
+		return fields, nil
+	}
+
+	selected := make([]bool, len(fields))
+	for _, name := range g.Fields {
+		found := false
+		for i, field := range fields {
+			if field.CLIName == name || field.Name == name || strings.HasPrefix(field.Name, name+".") {
+				selected[i], found = true, true
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("--fields names %q, which is neither a field nor a flag of the args struct", name)
+		}
+	}
+
+	var kept []FieldInfo
+	for i, field := range fields {
+		if selected[i] {
+			kept = append(kept, field)
+		} else {
+			g.logf("field %s: skipped, not selected by --fields", field.Name)
+		}
+	}
+	return kept, nil
+}
+
+// collectFields walks the fields of a struct, flattening named fields whose
+// type is a struct declared in the same file or an anonymous struct, as in
+// Opts struct{ A, B int }. Nested flags are prefixed with the outer flag name
+// and bound through the nested struct path.
+//
+// The order only depends on the source, so regenerating is idempotent: the
+// struct's own fields come first in declaration order, followed by the
+// fields of each nested struct in the declaration order of the fields
+// holding them, recursively.
+func (g *Generator) collectFields(structType *ast.StructType, cliPrefix, namePrefix string, visiting map[*ast.StructType]bool) ([]FieldInfo, error) {
+	var fields, nestedFields []FieldInfo
+	var section string
+
+	visiting[structType] = true
+	defer delete(visiting, structType)
+
+	for _, field := range splitFieldNames(structType.Fields.List) {
+		if field.Doc != nil {
+			for _, comment := range field.Doc.List {
+				if name, ok := strings.CutPrefix(comment.Text, "//cligen:section "); ok {
+					section = strings.TrimSpace(name)
+				}
+			}
+		}
 
-	for _, field := range structType.Fields.List {
 		if len(field.Names) == 0 {
+			g.logf("skipping embedded field %s", g.getTypeString(field.Type))
 			continue // Skip embedded fields
 		}
 
@@ -105,25 +961,228 @@ func (g *Generator) parseStructFields(structType *ast.StructType) ([]FieldInfo,
 			tag = strings.Trim(tag, "`")
 		}
 
+		if g.WarnTagKeys {
+			for _, key := range tagKeys(tag) {
+				if key != "cli" && editDistance(key, "cli") == 1 {
+					g.warnf("field %s%s: tag key %q is ignored, did you mean cli?", namePrefix, fieldName, key)
+				}
+			}
+		}
+
+		if g.extractTag(tag, "cli") == "-" {
+			g.logf("skipping field %s%s: tagged cli:\"-\"", namePrefix, fieldName)
+			continue
+		}
+
 		fieldInfo := g.parseFieldTag(fieldName, fieldType, tag)
+		if field.group != nil {
+			g.ungroup(&fieldInfo, fieldName, field.group, tag)
+		}
+		fieldInfo.Name = namePrefix + fieldInfo.Name
+		if fieldInfo.TypeOverride != "" {
+			// The generated command declares its own copy of the field, which
+			// can't name a type declared in the source package, alias or not,
+			// so the override replaces the type everywhere
+			g.logf("field %s: binding %s as %s", fieldInfo.Name, fieldType, fieldInfo.TypeOverride)
+			fieldInfo.Type = fieldInfo.TypeOverride
+		} else if g.stringTypes[fieldType] {
+			g.bindStringType(&fieldInfo)
+		}
+		g.applyValidateTag(&fieldInfo, tag)
+		fieldInfo.Group = section
+		fieldInfo.Pos = field.Pos()
+		if cliPrefix != "" {
+			fieldInfo.CLIName = g.joinFlagName(cliPrefix, fieldInfo.CLIName)
+		}
+
+		// Flatten nested struct types declared in the same file or inline. A
+		// pointer to one is an optional section.
+		structName, pointer := strings.CutPrefix(fieldType, "*")
+		if generic, _, ok := strings.Cut(structName, "["); ok && g.generics[generic] {
+			return nil, fieldErrorf(fieldInfo, "generic struct %s is not supported, declare a struct with concrete field types", fieldType)
+		}
+		nested, ok := g.structs[structName]
+		if inline := inlineStruct(field.Type); inline != nil {
+			nested, ok = inline, true
+		}
+		if ok {
+			if visiting[nested] {
+				return nil, fieldErrorf(fieldInfo, "recursive struct type %s", fieldType)
+			}
+
+			prefix := g.flagName(fieldName)
+			if cliTag := g.extractTag(tag, "cli"); cliTag != "" && field.group == nil {
+				if name := tagName(splitQuoted(cliTag, ',')); name != "" {
+					prefix = name
+				}
+			}
+			prefix = g.joinFlagName(cliPrefix, prefix)
+
+			g.logf("flattening field %s of type %s with prefix %q", fieldInfo.Name, fieldType, prefix)
+			g.prefixes[fieldInfo.Name] = prefix
+			flattened, err := g.collectFields(nested, prefix, fieldInfo.Name+".", visiting)
+			if err != nil {
+				return nil, err
+			}
+			for i := range flattened {
+				if flattened[i].Group == "" {
+					flattened[i].Group = section
+				}
+				if pointer {
+					flattened[i].Optional = append([]string{fieldInfo.Name}, flattened[i].Optional...)
+				}
+			}
+			nestedFields = append(nestedFields, flattened...)
+			continue
+		}
+
+		g.logf("field %s: --%s (%s) short=%q default=%q required=%t options=%v",
+			fieldInfo.Name, fieldInfo.CLIName, fieldInfo.Type, fieldInfo.ShortFlag,
+			fieldInfo.DefaultValue, fieldInfo.Required, fieldInfo.Options)
+
 		fields = append(fields, fieldInfo)
 	}
 
-	return fields, nil
+	return append(fields, nestedFields...), nil
+}
+
+// declaredField is a field of a struct with a single name
+type declaredField struct {
+	*ast.Field
+	// group holds every name of the declaration the field was split from,
+	// as in A, B int, and is nil for a field declared alone
+	group []*ast.Ident
+}
+
+// splitFieldNames splits the fields declaring several names, as in A, B int,
+// into a field per name sharing the type and tag, so each gets its own flag
+func splitFieldNames(list []*ast.Field) []declaredField {
+	var fields []declaredField
+	for _, field := range list {
+		if len(field.Names) <= 1 {
+			fields = append(fields, declaredField{Field: field})
+			continue
+		}
+		for i, name := range field.Names {
+			split := *field
+			split.Names = []*ast.Ident{name}
+			if i > 0 {
+				// The doc comment, and a //cligen:section in it, precedes the first
+				split.Doc = nil
+			}
+			fields = append(fields, declaredField{Field: &split, group: field.Names})
+		}
+	}
+	return fields
+}
+
+// ungroup names a field split from a declaration such as A, B []string
+// after the field alone. The flag name, short flag and aliases of a tag
+// they share would be claimed by each of them, so these are dropped, with
+// a warning on the first name, while the other modifiers apply to all.
+func (g *Generator) ungroup(field *FieldInfo, fieldName string, group []*ast.Ident, tag string) {
+	var dropped []string
+	if parts := splitQuoted(g.extractTag(tag, "cli"), ','); tagName(parts) != "" {
+		dropped = append(dropped, "flag name")
+	}
+	if field.ShortFlag != "" {
+		dropped = append(dropped, "short flag")
+	}
+	if len(field.Aliases) > 0 {
+		dropped = append(dropped, "aliases")
+	}
+
+	field.CLIName, field.ShortFlag, field.Aliases = g.flagName(fieldName), "", nil
+	if len(dropped) == 0 || fieldName != group[0].Name {
+		return
+	}
+	names := make([]string, len(group))
+	for i, name := range group {
+		names[i] = name.Name
+	}
+	last := len(dropped) - 1
+	what := strings.Join(dropped[:last], ", ")
+	if what != "" {
+		what += " and "
+	}
+	g.warnf("fields %s share a cli tag, so its %s%s can't apply and each is named after its field", strings.Join(names, ", "), what, dropped[last])
+}
+
+// inlineStruct returns the anonymous struct type of a field, or of the
+// pointer it is declared as, or nil
+func inlineStruct(expr ast.Expr) *ast.StructType {
+	if star, ok := expr.(*ast.StarExpr); ok {
+		expr = star.X
+	}
+	structType, _ := expr.(*ast.StructType)
+	return structType
+}
+
+// logf prints a diagnostic message to stderr when verbose logging is enabled
+func (g *Generator) logf(format string, args ...any) {
+	if g.Verbose {
+		log.Printf(format, args...)
+	}
+}
+
+// trace logs how long a phase of the generation has taken since start with
+// --trace. The subject names what the phase worked on, such as a command
+// or a file.
+func (g *Generator) trace(phase, subject string, start time.Time) {
+	if g.Trace {
+		log.Printf("trace: %s %s: %s", phase, subject, time.Since(start))
+	}
+}
+
+// warnf reports a non-fatal problem with the input to stderr. In strict
+// mode the warning is recorded instead and later fails the generation.
+func (g *Generator) warnf(format string, args ...any) {
+	if g.Strict {
+		g.warnings = append(g.warnings, fmt.Sprintf(format, args...))
+		return
+	}
+	fmt.Fprintf(os.Stderr, "Warning: "+format+"\n", args...)
+}
+
+// strictError returns the warnings recorded in strict mode as a single
+// error, or nil if there were none
+func (g *Generator) strictError() error {
+	if len(g.warnings) == 0 {
+		return nil
+	}
+	err := fmt.Errorf("strict mode: %s", strings.Join(g.warnings, "; "))
+	g.warnings = nil
+	return err
+}
+
+// kebabCase converts a Go identifier such as MaxRetries or TLSConfig to
+// its kebab-case form (max-retries, tls-config)
+func kebabCase(name string) string {
+	runes := []rune(name)
+	var b strings.Builder
+
+	for i, r := range runes {
+		if unicode.IsUpper(r) {
+			if i > 0 && (unicode.IsLower(runes[i-1]) ||
+				(i+1 < len(runes) && unicode.IsLower(runes[i+1]) && unicode.IsUpper(runes[i-1]))) {
+				b.WriteByte('-')
+			}
+			b.WriteRune(unicode.ToLower(r))
+			continue
+		}
+		b.WriteRune(r)
+	}
+
+	return b.String()
 }
 
-// getTypeString converts an ast.Expr to a type string
+// getTypeString converts an ast.Expr to a type string as written in the
+// source, with a variadic parameter's ...T reported as []T
 func (g *Generator) getTypeString(expr ast.Expr) string {
-	switch t := expr.(type) {
-	case *ast.Ident:
-		return t.Name
-	case *ast.ArrayType:
-		return "[]" + g.getTypeString(t.Elt)
-	case *ast.StarExpr:
-		return "*" + g.getTypeString(t.X)
-	default:
-		return "interface{}"
+	if ellipsis, ok := expr.(*ast.Ellipsis); ok {
+		return "[]" + g.getTypeString(ellipsis.Elt)
 	}
+	return types.ExprString(expr)
 }
 
 // parseFieldTag parses the cli struct tag
@@ -131,7 +1190,7 @@ func (g *Generator) parseFieldTag(fieldName, fieldType, tag string) FieldInfo {
 	field := FieldInfo{
 		Name:    fieldName,
 		Type:    fieldType,
-		CLIName: strings.ToLower(fieldName),
+		CLIName: g.flagName(fieldName),
 	}
 
 	if tag == "" {
@@ -144,30 +1203,197 @@ func (g *Generator) parseFieldTag(fieldName, fieldType, tag string) FieldInfo {
 		return field
 	}
 
-	parts := strings.Split(cliTag, ",")
+	parts := splitQuoted(cliTag, ',')
+	if keyedTag(parts) {
+		// long: and short: name the flag, so every part is a modifier
+		g.applyModifiers(&field, parts)
+		return field
+	}
+
 	if len(parts) > 0 && parts[0] != "" {
 		field.CLIName = parts[0]
 	}
 
-	for i := 1; i < len(parts); i++ {
-		part := strings.TrimSpace(parts[i])
+	g.applyModifiers(&field, parts[1:])
+
+	return field
+}
+
+// keyedTag reports whether a cli tag names its flag with long: or short:
+// instead of a leading name, as in cli:"long:max-retries,short:r"
+func keyedTag(parts []string) bool {
+	if len(parts) > 0 && (strings.HasPrefix(parts[0], "long:") || strings.HasPrefix(parts[0], "short:")) {
+		return true
+	}
+	for _, part := range parts {
+		if strings.HasPrefix(strings.TrimSpace(part), "long:") {
+			return true
+		}
+	}
+	return false
+}
+
+// tagName returns the flag name a cli tag gives explicitly, in either form
+func tagName(parts []string) string {
+	if !keyedTag(parts) {
+		return parts[0]
+	}
+	for _, part := range parts {
+		if name, ok := strings.CutPrefix(strings.TrimSpace(part), "long:"); ok {
+			return name
+		}
+	}
+	return ""
+}
+
+// applyModifiers applies the modifiers following the flag name in a cli tag
+func (g *Generator) applyModifiers(field *FieldInfo, parts []string) {
+	var description, help string
+
+	for _, part := range parts {
+		part = strings.TrimSpace(part)
 
 		if len(part) == 1 {
 			// Single character is a short flag
 			field.ShortFlag = part
+		} else if strings.HasPrefix(part, "long:") {
+			field.CLIName = strings.TrimPrefix(part, "long:")
+		} else if strings.HasPrefix(part, "short:") {
+			field.ShortFlag = strings.TrimPrefix(part, "short:")
+		} else if strings.HasPrefix(part, "default:func:") {
+			field.DefaultFunc = strings.TrimPrefix(part, "default:func:")
 		} else if strings.HasPrefix(part, "default:") {
 			field.DefaultValue = strings.TrimPrefix(part, "default:")
 		} else if part == "required" {
 			field.Required = true
+		} else if strings.HasPrefix(part, "required:") {
+			field.Required = true
+			field.RequiredMessage = strings.TrimPrefix(part, "required:")
+			if len(field.RequiredMessage) >= 2 && field.RequiredMessage[0] == '"' && field.RequiredMessage[len(field.RequiredMessage)-1] == '"' {
+				field.RequiredMessage = field.RequiredMessage[1 : len(field.RequiredMessage)-1]
+			}
+		} else if part == "positional" {
+			field.Positional = true
+		} else if part == "variadic" {
+			field.Variadic = true
+		} else if part == "validate" {
+			field.Validate = true
+		} else if part == "secret" {
+			field.Secret = true
+		} else if part == "passthrough" {
+			field.Passthrough = true
+		} else if part == "stdio" {
+			field.Stdio = true
+		} else if part == "noshort" {
+			field.NoShort = true
+		} else if part == "hidden-default" {
+			field.HideDefault = true
+		} else if part == "count" {
+			field.Count = true
+		} else if part == "humansize" {
+			field.Human = "size"
+		} else if part == "humancount" {
+			field.Human = "count"
+		} else if strings.HasPrefix(part, "max:") {
+			field.Max = strings.TrimPrefix(part, "max:")
+		} else if strings.HasPrefix(part, "order:") {
+			order, err := strconv.Atoi(strings.TrimPrefix(part, "order:"))
+			if err != nil {
+				g.warnf("field %s: %s is not a whole number, so the field keeps its place", field.Name, part)
+				continue
+			}
+			field.Order = order
 		} else if strings.HasPrefix(part, "options:") {
 			optionsStr := strings.TrimPrefix(part, "options:")
-			field.Options = strings.Split(optionsStr, "|")
+			field.Options = nil
+			for _, option := range splitQuoted(optionsStr, '|') {
+				if len(option) >= 2 && option[0] == '"' && option[len(option)-1] == '"' {
+					option = option[1 : len(option)-1]
+				}
+				field.Options = append(field.Options, option)
+			}
+		} else if strings.HasPrefix(part, "optionsfrom:") {
+			field.OptionsFrom = strings.TrimPrefix(part, "optionsfrom:")
 		} else if strings.HasPrefix(part, "usage:") {
 			field.Usage = strings.TrimPrefix(part, "usage:")
+		} else if strings.HasPrefix(part, "description:") {
+			description = strings.TrimPrefix(part, "description:")
+		} else if strings.HasPrefix(part, "help:") {
+			help = strings.TrimPrefix(part, "help:")
+		} else if strings.HasPrefix(part, "alias:") {
+			field.Aliases = append(field.Aliases, strings.Split(strings.TrimPrefix(part, "alias:"), "|")...)
+		} else if strings.HasPrefix(part, "env:") {
+			field.Env = strings.TrimPrefix(part, "env:")
+		} else if strings.HasPrefix(part, "layout:") {
+			field.Layout = strings.TrimPrefix(part, "layout:")
+		} else if strings.HasPrefix(part, "placeholder:") {
+			field.Placeholder = strings.TrimPrefix(part, "placeholder:")
+		} else if strings.HasPrefix(part, "type:") {
+			field.TypeOverride = strings.TrimPrefix(part, "type:")
 		}
 	}
 
-	return field
+	// description: wins over help: regardless of their order in the tag
+	if field.Usage != "" && (description != "" || help != "") {
+		g.warnf("field %s: usage: is given, so description: and help: are ignored", field.Name)
+	} else if description != "" && help != "" {
+		g.warnf("field %s: description: is given, so help: is ignored", field.Name)
+	}
+	if description != "" {
+		field.Help = description
+	} else if help != "" {
+		field.Help = help
+	}
+
+	// Bool defaults are inlined into the generated code, so spell them as
+	// a Go literal, as are human sizes and counts. Unrecognized spellings
+	// are reported by validateFields.
+	if field.Type == "bool" && field.DefaultValue != "" {
+		if value, ok := parseBool(field.DefaultValue); ok {
+			field.DefaultValue = strconv.FormatBool(value)
+		}
+	}
+	if field.Human != "" && field.DefaultValue != "" {
+		if value, err := parseHuman(field.DefaultValue, field.Human == "size"); err == nil {
+			field.DefaultValue = strconv.FormatUint(value, 10)
+		}
+	}
+}
+
+// splitQuoted splits s at each sep that is not inside double quotes. The
+// quotes are kept so that callers can tell quoted values apart.
+func splitQuoted(s string, sep rune) []string {
+	var parts []string
+	var current strings.Builder
+	inQuotes := false
+
+	for _, r := range s {
+		switch {
+		case r == '"':
+			inQuotes = !inQuotes
+			current.WriteRune(r)
+		case r == sep && !inQuotes:
+			parts = append(parts, current.String())
+			current.Reset()
+		default:
+			current.WriteRune(r)
+		}
+	}
+
+	return append(parts, current.String())
+}
+
+// parseBool parses a bool written any way strconv.ParseBool accepts, or as
+// yes/no or on/off
+func parseBool(s string) (bool, bool) {
+	switch strings.ToLower(s) {
+	case "yes", "on":
+		return true, true
+	case "no", "off":
+		return false, true
+	}
+	value, err := strconv.ParseBool(s)
+	return value, err == nil
 }
 
 // extractTag extracts a specific tag from a struct tag string
@@ -177,117 +1403,491 @@ func (g *Generator) extractTag(tag, key string) string {
 	return structTag.Get(key)
 }
 
+// templateData is the data passed to the code templates
+type templateData struct {
+	Command    string
+	Help       string
+	StructName string
+	Fields     []FieldInfo
+	Struct     []StructField
+	Groups     []FlagGroup
+	// Optionals lists the pointer sections allocated while parsing
+	Optionals []OptionalSection
+	// Positionals lists the positional fields in argument order, and
+	// RequiredArgs reports whether any of them is required
+	Positionals  []FieldInfo
+	RequiredArgs bool
+	// Passthrough is the field capturing the arguments after --
+	Passthrough *FieldInfo
+	// ArgsUsage describes the positional arguments in the usage line
+	ArgsUsage string
+	// HelpName is how the command is run, as in serve or app serve, for the
+	// usage line and the --help hints. UsageLine replaces the whole usage
+	// line when set by a //cligen:usage annotation.
+	HelpName  string
+	UsageLine string
+
+	// Program is the binary name in subcommand mode
+	Program string
+	// Commands lists every command of the program in subcommand mode, and
+	// Globals the //cligen:global flags they share
+	Commands []Command
+	Globals  []FieldInfo
+	// LocalFlags registers the flags on a per-command FlagSet instead of
+	// the global pflag.CommandLine
+	LocalFlags bool
+	// Main emits a func main for the command
+	Main bool
+	// Backend is the flag package the code is generated for
+	Backend string
+	// Homepage is the documentation URL shown at the bottom of the help
+	Homepage string
+	// Stringer emits String and GoString methods for the command
+	Stringer bool
+	// Invocation and Source record how the file was generated
+	Invocation string
+	Source     string
+	// Required and Options emit the checks for required flags and for
+	// restricted values, which are left out entirely when not needed
+	Required bool
+	Options  bool
+	// Bounds emits the checks of the min= and max= of validate tags
+	Bounds bool
+	// Enums emits the flag.Value checking options while parsing
+	Enums bool
+	// Maxes emits the clamping of counts with a max:
+	Maxes bool
+	// Env emits the fallback to environment variables for unset flags, and
+	// RequiredEnv records which of them were set from the environment, where
+	// a required flag counts as given
+	Env         bool
+	RequiredEnv bool
+	// DefaultFuncs emits the calls of default:func: functions for unset flags
+	DefaultFuncs bool
+	// SortFlags and HelpWidth control the layout of the flag usage
+	SortFlags bool
+	HelpWidth int
+	// OutputFormat emits the Render helper for the --output flag
+	OutputFormat bool
+	// Color registers --color and prints errors through errorf
+	Color bool
+	// Completion checks for --generate-completion before parsing, and
+	// Completions holds the script of each shell keyed by its name
+	Completion  bool
+	Completions map[string]string
+	// WindowsFlags translates /name:value arguments before parsing
+	WindowsFlags bool
+	// Interactive prompts for the missing required flags on a terminal
+	Interactive bool
+	// WriteConfig registers --write-config, and ConfigTemplate is the Go
+	// string literal of the file it writes
+	WriteConfig    bool
+	ConfigTemplate string
+	// Implements is the interface the command is asserted to implement
+	Implements string
+	// DefaultStyle is inline or none when the help doesn't show defaults as
+	// the flag package does, see Generator.DefaultStyle
+	DefaultStyle string
+	// Times emits the flag.Value used by time.Time fields
+	Times bool
+	// Humans emits the flag.Value of humansize and humancount fields
+	Humans bool
+	// Aliases registers the alternative names of flags
+	Aliases bool
+	// Validators lists the fields with validation hooks
+	Validators []FieldInfo
+	// Stdio lists the path fields given Open and Create methods
+	Stdio []FieldInfo
+	// Append renders only the additions to an existing file
+	Append bool
+}
+
 // generateCLICode generates the CLI code using templates
 func (g *Generator) generateCLICode(structName string, fields []FieldInfo) error {
 	// Create directory if it doesn't exist
-	if err := os.MkdirAll(strings.TrimSuffix(g.OutputFile, "/main.go"), 0755); err != nil {
-		return fmt.Errorf("failed to create output directory: %w", err)
-	}
-
-	caser := cases.Title(language.English)
-
-	// Load CLI template from embedded file
-	cliTemplateContent, err := templateFS.ReadFile("templates/cli.go.tmpl")
-	if err != nil {
-		return fmt.Errorf("failed to read CLI template: %w", err)
+	if err := g.makeOutputDir(); err != nil {
+		return err
 	}
 
-	tmpl := template.Must(template.New("cli").Funcs(template.FuncMap{
-		"title": caser.String,
-		"join":  strings.Join,
-	}).Parse(string(cliTemplateContent)))
-
-	data := struct {
-		Command    string
-		Help       string
-		StructName string
-		Fields     []FieldInfo
-	}{
-		Command:    g.Command,
+	cmd := Command{
+		Name:       g.Command,
 		Help:       g.Help,
 		StructName: structName,
 		Fields:     fields,
 	}
+	g.readAnnotations(&cmd)
+	if err := expandHelp(cmd.Name, cmd.Fields); err != nil {
+		return err
+	}
 
-	file, err := os.Create(g.OutputFile)
-	if err != nil {
-		return fmt.Errorf("failed to create output file: %w", err)
+	data := g.commandData(cmd)
+
+	if err := g.renderTemplate("cli", g.OutputFile, data); err != nil {
+		return err
 	}
-	defer func() {
-		if closeErr := file.Close(); closeErr != nil {
-			fmt.Fprintf(os.Stderr, "Warning: failed to close file %s: %v\n", g.OutputFile, closeErr)
-		}
-	}()
 
-	if err := tmpl.Execute(file, data); err != nil {
+	if err := g.generateColorHelper(); err != nil {
+		return err
+	}
+
+	if err := g.generateCompletion(g.Command, []Command{cmd}, false); err != nil {
 		return err
 	}
 
 	// Generate go.mod file for the command
-	if err := g.generateGoMod(); err != nil {
+	if err := g.generateGoMod(g.moduleName(g.Command)); err != nil {
+		return err
+	}
+
+	// Generate validation hook stubs that don't exist yet
+	if err := g.generateValidationStubs(g.Command, fields); err != nil {
 		return err
 	}
 
 	// Generate implementation stub file if it doesn't exist
-	return g.generateImplementationStub(structName, fields)
+	return g.generateImplementationStub(g.Command, structName, fields)
 }
 
-// generateGoMod creates a go.mod file for the command
-func (g *Generator) generateGoMod() error {
-	dir := strings.TrimSuffix(g.OutputFile, "/main.go")
-	goModPath := fmt.Sprintf("%s/go.mod", dir)
+// commandData builds the template data for rendering a single command
+func (g *Generator) commandData(cmd Command) templateData {
+	cmd.Fields = withEnvPrefix(cmd.Fields, cmd.EnvPrefix)
+	if g.EnumTypes {
+		cmd.Fields = withEnums(cmd.Fields)
+	}
 
-	goModContent := fmt.Sprintf(`module %s
+	return templateData{
+		Command:      cmd.Name,
+		Help:         cmd.Help,
+		StructName:   cmd.StructName,
+		Fields:       cmd.Fields,
+		Struct:       g.buildStructFields(cmd.Fields),
+		Optionals:    buildOptionals(cmd.Fields),
+		Groups:       buildGroups(cmd.Fields),
+		Positionals:  positionals(cmd.Fields),
+		RequiredArgs: hasRequiredArgs(cmd.Fields),
+		Passthrough:  passthrough(cmd.Fields),
+		ArgsUsage:    argsUsage(cmd.Fields),
+		Validators:   validators(cmd.Fields),
+		Stdio:        stdioFields(cmd.Fields),
+		Required:     hasRequired(cmd.Fields),
+		Options:      hasOptions(cmd.Fields),
+		Bounds:       hasBounds(cmd.Fields),
+		Enums:        hasEnums(cmd.Fields),
+		Maxes:        hasMaxes(cmd.Fields),
+		Env:          hasEnv(cmd.Fields),
+		RequiredEnv:  hasRequiredEnv(cmd.Fields),
+		DefaultFuncs: hasDefaultFuncs(cmd.Fields),
+		Times:        hasTime(cmd.Fields),
+		Humans:       hasHumans(cmd.Fields),
+		Aliases:      hasAliases(cmd.Fields),
+		OutputFormat: g.OutputFormat,
+		SortFlags:    g.SortFlags,
+		HelpWidth:    g.HelpWidth,
+		WindowsFlags: g.WindowsFlags,
+		Interactive:  g.Interactive && hasRequired(cmd.Fields),
+		Implements:   g.implementedType(),
+		DefaultStyle: g.DefaultStyle,
+		Color:        g.Color,
+		// The keys of nested structs depend on the prefixes of the command
+		WriteConfig:    g.WriteConfig,
+		ConfigTemplate: g.configTemplate(cmd.Name, cmd.Fields),
+		// Cobra owns the FlagSet of the commands it runs
+		LocalFlags: g.LocalFlags || g.Backend == "cobra",
+		Main:       !g.NoMain,
+		Completion: g.Completion,
+		Backend:    g.Backend,
+		Homepage:   cmd.Homepage,
+		HelpName:   g.helpName(cmd.Name),
+		UsageLine:  g.usageLine(cmd),
+		Stringer:   g.Stringer,
+		Invocation: g.Invocation,
+		Source:     g.SourceFile,
+	}
+}
 
-go 1.24
+// helpName returns how a command is run: by the --bin-name or the command
+// name, or by the program and the command name with --subcommands
+func (g *Generator) helpName(command string) string {
+	switch {
+	case g.Program != "":
+		return g.Program + " " + command
+	case g.BinName != "":
+		return g.BinName
+	}
+	return command
+}
+
+// usageLine returns the //cligen:usage annotation of a command with the
+// {{.Bin}} placeholder replaced by the program name, or "" without one
+func (g *Generator) usageLine(cmd Command) string {
+	if cmd.Usage == "" {
+		return ""
+	}
+	bin := cmd.Name
+	if g.Program != "" {
+		bin = g.Program
+	} else if g.BinName != "" {
+		bin = g.BinName
+	}
+	return strings.ReplaceAll(cmd.Usage, "{{.Bin}}", bin)
+}
 
-require github.com/spf13/pflag v1.0.6
-`, g.Command)
+// renderTemplate executes the named embedded template into the given file
+// and formats it with gofmt. Code that fails to format leaves the file as it
+// was, and is written as rendered to <file>.unformatted instead, so the
+// template bug can be inspected.
+func (g *Generator) renderTemplate(name, path string, data templateData) error {
+	start := time.Now()
+	var buf bytes.Buffer
+	if err := g.executeTemplate(name, &buf, data); err != nil {
+		return err
+	}
+	g.trace("render", path, start)
 
-	return os.WriteFile(goModPath, []byte(goModContent), 0644)
+	code := g.withHeader(buf.Bytes())
+	if name == "cli" && g.implemented != nil && g.implemented.Import != nil {
+		// The interface's package may be one the template imports already
+		var err error
+		if code, err = mergeImports(string(code), []*ast.ImportSpec{g.implemented.Import}); err != nil {
+			return err
+		}
+	}
+	if g.Insert && name == "cli" {
+		var err error
+		if code, err = g.insertBlock(path, data.Command, code); err != nil {
+			return err
+		}
+	}
+	if !g.NoFormat {
+		start := time.Now()
+		formatted, err := format.Source(code)
+		g.trace("format", path, start)
+		if err != nil {
+			raw := path + ".unformatted"
+			if g.PrintOutputs {
+				return fmt.Errorf("failed to format %s: %w", path, err)
+			}
+			if err := replaceFile(raw, code, 0); err != nil {
+				return fmt.Errorf("failed to write output file: %w", err)
+			}
+			return fmt.Errorf("failed to format %s, which was left as it was; the rendered code is in %s: %w", path, raw, err)
+		}
+		code = formatted
+	}
+
+	if err := g.writeFile(name, path, code); err != nil {
+		return fmt.Errorf("failed to write output file: %w", err)
+	}
+	return nil
 }
 
-// generateImplementationStub creates an implementation stub file if it doesn't exist
-func (g *Generator) generateImplementationStub(structName string, fields []FieldInfo) error {
-	dir := strings.TrimSuffix(g.OutputFile, "/main.go")
-	implPath := fmt.Sprintf("%s/%s_impl.go", dir, g.Command)
+// writeFile writes a rendered template to path. The --perm mode applies to
+// the files cligen regenerates, leaving the implementation and validation
+// stubs, which are written once and then edited, with the default mode.
+// The code is rendered in full before anything is written, and replaces the
+// file in one step, so a failed run leaves the previous output intact.
+func (g *Generator) writeFile(name, path string, code []byte) error {
+	if g.PrintOutputs {
+		// The stubs aren't outputs a build can depend on, as they are only
+		// written when missing
+		if name != "impl" && name != "validate" {
+			g.Outputs = append(g.Outputs, path)
+		}
+		return nil
+	}
+	if g.Perm == 0 || name == "impl" || name == "validate" {
+		return replaceFile(path, code, 0)
+	}
+	return replaceFile(path, code, g.Perm)
+}
 
-	// Don't overwrite existing implementation
-	if _, err := os.Stat(implPath); err == nil {
-		return nil // File already exists, don't overwrite
+// replaceFile writes data to a temporary file next to path and renames it
+// over path once it is complete, so an error or an interrupted run never
+// leaves a truncated file behind. A zero perm keeps the mode of the file
+// being replaced, creating a new one as 0644 less the umask like
+// os.WriteFile. A read-only file from an earlier run is replaced as well.
+func replaceFile(path string, data []byte, perm os.FileMode) error {
+	mode, exact := perm, perm != 0
+	if info, err := os.Stat(path); err == nil && !exact {
+		mode, exact = info.Mode().Perm(), true
+	} else if !exact {
+		mode = 0644
 	}
 
+	tmp := filepath.Join(filepath.Dir(path), "."+filepath.Base(path)+".tmp")
+	// A temporary file left by an interrupted run is replaced
+	if err := os.Remove(tmp); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	file, err := os.OpenFile(tmp, os.O_CREATE|os.O_EXCL|os.O_WRONLY, mode)
+	if err != nil {
+		return err
+	}
+	_, err = file.Write(data)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil && exact {
+		// OpenFile applies the umask, which would defeat an explicit mode
+		err = os.Chmod(tmp, mode)
+	}
+	if err == nil {
+		err = os.Rename(tmp, path)
+	}
+	if err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+	return nil
+}
+
+// withHeader inserts the --header-file comments into generated code,
+// either first or after the leading // comments holding the generated-code
+// marker. Either way the header is separated by blank lines, so it never
+// becomes the package doc comment and build constraints keep their place
+// above the package clause.
+func (g *Generator) withHeader(code []byte) []byte {
+	if g.Header == "" {
+		return code
+	}
+
+	header := strings.TrimRight(g.Header, "\n") + "\n\n"
+	if !g.HeaderAfter {
+		return append([]byte(header), code...)
+	}
+
+	var marker int
+	for marker < len(code) && bytes.HasPrefix(code[marker:], []byte("//")) {
+		end := bytes.IndexByte(code[marker:], '\n')
+		if end < 0 {
+			break
+		}
+		marker += end + 1
+	}
+	if marker == 0 {
+		return append([]byte(header), code...)
+	}
+
+	out := append([]byte{}, code[:marker]...)
+	out = append(out, '\n')
+	out = append(out, header[:len(header)-1]...)
+	return append(out, code[marker:]...)
+}
+
+// executeTemplate executes the named embedded template into w
+func (g *Generator) executeTemplate(name string, w io.Writer, data templateData) error {
 	caser := cases.Title(language.English)
 
-	// Load implementation template from embedded file
-	implTemplateContent, err := templateFS.ReadFile("templates/impl.go.tmpl")
+	// Load template from embedded file
+	content, err := templateFS.ReadFile(fmt.Sprintf("templates/%s.go.tmpl", name))
 	if err != nil {
-		return fmt.Errorf("failed to read implementation template: %w", err)
+		return fmt.Errorf("failed to read %s template: %w", name, err)
 	}
 
-	tmpl := template.Must(template.New("impl").Funcs(template.FuncMap{
-		"title": caser.String,
-	}).Parse(string(implTemplateContent)))
+	tmpl := template.Must(template.New(name).Funcs(template.FuncMap{
+		"title":     caser.String,
+		"join":      strings.Join,
+		"validator": validatorName,
+		"stdio":     stdioName,
+		"quote":     strconv.Quote,
+	}).Parse(string(content)))
 
-	data := struct {
-		Command    string
-		StructName string
-		Fields     []FieldInfo
-	}{
-		Command:    g.Command,
-		StructName: structName,
-		Fields:     fields,
+	return tmpl.Execute(w, data)
+}
+
+// outputDir returns the directory the generated files are written to
+func (g *Generator) outputDir() string {
+	return filepath.Dir(g.OutputFile)
+}
+
+// makeOutputDir creates the output directory if it doesn't exist
+func (g *Generator) makeOutputDir() error {
+	if g.PrintOutputs {
+		return nil
 	}
+	if err := os.MkdirAll(g.outputDir(), 0755); err != nil {
+		return fmt.Errorf("failed to create output directory: %w", err)
+	}
+	return nil
+}
+
+// moduleName returns the module path for the generated go.mod. Inside a
+// known module the nested module is named after its import path.
+func (g *Generator) moduleName(fallback string) string {
+	if importPath := g.importPath(g.outputDir()); importPath != "" {
+		return importPath
+	}
+	return fallback
+}
+
+// generateGoMod creates a go.mod file for the command
+func (g *Generator) generateGoMod(module string) error {
+	goModPath := filepath.Join(g.outputDir(), "go.mod")
 
-	file, err := os.Create(implPath)
+	goModContent := fmt.Sprintf(`module %s
+
+go 1.24
+`, module)
+	if g.Backend == "cobra" {
+		goModContent += "\nrequire github.com/spf13/cobra v1.8.1\n"
+	}
+	if g.Backend != "stdflag" {
+		goModContent += "\nrequire github.com/spf13/pflag v1.0.6\n"
+	}
+	if g.OutputFormat {
+		goModContent += "\nrequire " + yamlModule + "\n"
+	}
+	directives, err := g.moduleDirectives()
 	if err != nil {
-		return fmt.Errorf("failed to create implementation file: %w", err)
+		return err
 	}
-	defer func() {
-		if closeErr := file.Close(); closeErr != nil {
-			fmt.Fprintf(os.Stderr, "Warning: failed to close file %s: %v\n", implPath, closeErr)
+	for _, directive := range directives {
+		// The interface may come from a module required above
+		if module, _, _ := strings.Cut(strings.TrimPrefix(directive, "require "), " "); !strings.Contains(goModContent, "\nrequire "+module+" ") {
+			goModContent += "\n" + directive + "\n"
 		}
-	}()
+	}
+
+	if g.PrintOutputs {
+		g.Outputs = append(g.Outputs, goModPath)
+		return nil
+	}
+	return replaceFile(goModPath, []byte(goModContent), 0)
+}
+
+// generateColorHelper writes the error coloring helper shared by the
+// commands in the output directory
+func (g *Generator) generateColorHelper() error {
+	if !g.Color {
+		return nil
+	}
+
+	data := templateData{
+		Invocation: g.Invocation,
+		Source:     g.SourceFile,
+	}
+	return g.renderTemplate("color", filepath.Join(g.outputDir(), "color.go"), data)
+}
+
+// generateImplementationStub creates an implementation stub file if it doesn't exist
+func (g *Generator) generateImplementationStub(command, structName string, fields []FieldInfo) error {
+	implPath := filepath.Join(g.outputDir(), fmt.Sprintf("%s_impl.go", command))
+
+	// Don't overwrite existing implementation
+	if _, err := os.Stat(implPath); err == nil {
+		return nil // File already exists, don't overwrite
+	}
+
+	data := templateData{
+		Command:      command,
+		StructName:   structName,
+		Fields:       fields,
+		OutputFormat: g.OutputFormat,
+	}
+
+	if err := g.renderTemplate("impl", implPath, data); err != nil {
+		return fmt.Errorf("failed to create implementation file: %w", err)
+	}
 
-	return tmpl.Execute(file, data)
+	return nil
 }
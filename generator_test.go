@@ -0,0 +1,1110 @@
+package main
+
+import (
+	"go/format"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+const serveSource = `package main
+
+//go:generate cligen serve "Starts an http server"
+type ServeArgs struct {
+	Port int ` + "`cli:\"port,p,default:8080\"`" + `
+}
+`
+
+func TestFormatErrorLeavesOutputUntouched(t *testing.T) {
+	dir := writeFiles(t, map[string]string{"source.go": serveSource})
+	cligen(t, dir, "serve", "Starts an http server")
+	path := filepath.Join(dir, "cmd", "serve", "main.go")
+	before := readFile(t, path)
+
+	// A header that isn't a comment can't come from --header-file, which
+	// checks it, so it stands in for a template rendering broken code
+	t.Chdir(dir)
+	g := &Generator{
+		SourceFile: "source.go",
+		Command:    "serve",
+		Help:       "Starts an http server",
+		OutputFile: "cmd/serve/main.go",
+		Backend:    "pflag",
+		Header:     "not go code",
+	}
+	if err := g.Generate(); err == nil {
+		t.Fatal("generating code that fails to format succeeded")
+	}
+	if after := readFile(t, path); after != before {
+		t.Errorf("a format error changed %s:\n%s", path, after)
+	}
+	if _, err := os.Stat(path + ".unformatted"); err != nil {
+		t.Errorf("the rendered code wasn't kept: %v", err)
+	}
+}
+
+func TestNestedStructFlags(t *testing.T) {
+	dir := generate(t, `package main
+
+type TLSConfig struct {
+	Cert string `+"`cli:\"cert,usage:Certificate file\"`"+`
+	Enabled bool
+}
+
+type ServeArgs struct {
+	Port int
+	TLS  TLSConfig
+	Retry struct {
+		Count int `+"`cli:\"count,default:3\"`"+`
+	}
+}
+`, "serve", "Starts an http server")
+	app := filepath.Join(dir, "cmd", "serve")
+	writeHandler(t, app, "serve", `fmt.Println(args.Port, args.TLS.Cert, args.TLS.Enabled, args.Retry.Count)`)
+	bin := buildCommand(t, app)
+
+	out, err := runCommand(bin, "--port=1", "--tls-cert=a.pem", "--tls-enabled", "--retry-count=5")
+	if err != nil || out != "1 a.pem true 5\n" {
+		t.Errorf("nested flags gave %q, %v, want \"1 a.pem true 5\\n\"", out, err)
+	}
+	if out, _ := runCommand(bin, "--help"); !strings.Contains(out, "--tls-cert string") || !strings.Contains(out, "Certificate file") {
+		t.Errorf("--help lacks --tls-cert:\n%s", out)
+	}
+}
+
+func TestVerboseLogsMatching(t *testing.T) {
+	dir := writeFiles(t, map[string]string{"source.go": serveSource})
+	out := cligen(t, dir, "--verbose", "serve", "Starts an http server")
+	for _, want := range []string{"matched struct ServeArgs", `field Port: --port (int) short="p" default="8080"`} {
+		if !strings.Contains(out, want) {
+			t.Errorf("--verbose output lacks %q:\n%s", want, out)
+		}
+	}
+	if out := cligen(t, dir, "serve", "Starts an http server"); strings.Contains(out, "matched struct") {
+		t.Errorf("matching logged without --verbose:\n%s", out)
+	}
+}
+
+const sectionsSource = `package main
+
+type ServeArgs struct {
+	Verbose bool ` + "`cli:\"verbose,v\"`" + `
+	//cligen:section Networking
+	Port int    ` + "`cli:\"port,p,default:8080\"`" + `
+	Host string ` + "`cli:\"host\"`" + `
+}
+`
+
+func TestHelpSections(t *testing.T) {
+	dir := generate(t, sectionsSource, "serve", "Starts an http server")
+	bin := buildCommand(t, filepath.Join(dir, "cmd", "serve"))
+
+	out, _ := runCommand(bin, "--help")
+	verbose, networking, port := strings.Index(out, "--verbose"), strings.Index(out, "Networking:"), strings.Index(out, "--port")
+	if verbose < 0 || networking < verbose || port < networking {
+		t.Errorf("--help doesn't list --port under Networking, after --verbose:\n%s", out)
+	}
+}
+
+func TestHelpForSection(t *testing.T) {
+	dir := generate(t, sectionsSource, "serve", "Starts an http server")
+	bin := buildCommand(t, filepath.Join(dir, "cmd", "serve"))
+
+	out, _ := runCommand(bin, "--help=networking")
+	if !strings.Contains(out, "--port") || !strings.Contains(out, "--host") || strings.Contains(out, "--verbose") {
+		t.Errorf("--help=networking doesn't list only the Networking flags:\n%s", out)
+	}
+	if out, err := runCommand(bin, "--help=storage"); err == nil || !strings.Contains(out, "Networking") {
+		t.Errorf("--help=storage gave %v, want a failure listing the sections:\n%s", err, out)
+	}
+}
+
+func TestStdflagBackend(t *testing.T) {
+	dir := generate(t, serveSource, "--backend=stdflag", "serve", "Starts an http server")
+	app := filepath.Join(dir, "cmd", "serve")
+	if mod := readFile(t, filepath.Join(app, "go.mod")); strings.Contains(mod, "require") {
+		t.Errorf("the stdflag go.mod has requirements:\n%s", mod)
+	}
+	if code := readFile(t, filepath.Join(app, "main.go")); strings.Contains(code, "pflag") {
+		t.Error("the stdflag command uses pflag")
+	}
+	writeHandler(t, app, "serve", `fmt.Println(args.Port)`)
+	bin := buildCommand(t, app)
+
+	for _, args := range [][]string{{"-port", "1"}, {"-p", "1"}, {"--port=1"}} {
+		if out, err := runCommand(bin, args...); err != nil || out != "1\n" {
+			t.Errorf("%v gave %q, %v, want \"1\\n\"", args, out, err)
+		}
+	}
+	if out, err := runCommand(bin); err != nil || out != "8080\n" {
+		t.Errorf("the default gave %q, %v, want \"8080\\n\"", out, err)
+	}
+}
+
+func TestQuiet(t *testing.T) {
+	dir := writeFiles(t, map[string]string{"source.go": serveSource})
+	if out := cligen(t, dir, "serve", "Starts an http server"); !strings.Contains(out, "Generated CLI code in cmd/serve/main.go") {
+		t.Errorf("generation didn't report the output:\n%s", out)
+	}
+	if out := cligen(t, dir, "--quiet", "serve", "Starts an http server"); out != "" {
+		t.Errorf("--quiet printed %q", out)
+	}
+}
+
+func TestStringer(t *testing.T) {
+	dir := generate(t, `package main
+
+type LoginArgs struct {
+	User  string `+"`cli:\"user\"`"+`
+	Token string `+"`cli:\"token,secret\"`"+`
+}
+`, "--stringer", "login", "Logs in")
+	app := filepath.Join(dir, "cmd", "login")
+	writeHandler(t, app, "login", `fmt.Println(args.String())
+fmt.Println(args.GoString())`)
+	bin := buildCommand(t, app)
+
+	out, err := runCommand(bin, "--user=ann", "--token=hunter2")
+	if err != nil {
+		t.Fatalf("%v\n%s", err, out)
+	}
+	if strings.Contains(out, "hunter2") || !strings.Contains(out, "user=ann") || !strings.Contains(out, "***") {
+		t.Errorf("String and GoString gave %q, want the user and the token redacted", out)
+	}
+}
+
+func TestSeveralCommands(t *testing.T) {
+	dir := generate(t, insertSource, "--command=serve", "--help=Starts a server", "--command=build", "--help=Builds the site", "--output=tools/build/main.go")
+	for path, help := range map[string]string{"cmd/serve": "Starts a server", "tools/build": "Builds the site"} {
+		bin := buildCommand(t, filepath.Join(dir, filepath.FromSlash(path)))
+		if out, _ := runCommand(bin, "--help"); !strings.Contains(out, help) {
+			t.Errorf("%s --help lacks %q:\n%s", path, help, out)
+		}
+	}
+}
+
+func TestOptionalSection(t *testing.T) {
+	dir := generate(t, `package main
+
+type TLSConfig struct {
+	Cert string `+"`cli:\"cert,required\"`"+`
+	Key  string `+"`cli:\"key,default:key.pem\"`"+`
+}
+
+type ServeArgs struct {
+	Port int        `+"`cli:\"port,default:8080\"`"+`
+	TLS  *TLSConfig
+}
+`, "serve", "Starts an http server")
+	app := filepath.Join(dir, "cmd", "serve")
+	writeHandler(t, app, "serve", `if args.TLS == nil {
+	fmt.Println("no tls")
+} else {
+	fmt.Println(args.TLS.Cert, args.TLS.Key)
+}`)
+	bin := buildCommand(t, app)
+
+	for _, tt := range []struct {
+		args []string
+		want string
+	}{
+		{nil, "no tls\n"},
+		{[]string{"--tls-cert=a.pem"}, "a.pem key.pem\n"},
+	} {
+		if out, err := runCommand(bin, tt.args...); err != nil || out != tt.want {
+			t.Errorf("%v gave %q, %v, want %q", tt.args, out, err, tt.want)
+		}
+	}
+	if out, err := runCommand(bin, "--tls-key=k.pem"); err == nil || !strings.Contains(out, "--tls-cert is required") {
+		t.Errorf("a TLS section without --tls-cert gave %v, want it required:\n%s", err, out)
+	}
+}
+
+func TestLocalFlagSet(t *testing.T) {
+	dir := generate(t, serveSource, "--flagset=local", "serve", "Starts an http server")
+	app := filepath.Join(dir, "cmd", "serve")
+	if code := readFile(t, filepath.Join(app, "main.go")); strings.Contains(code, "pflag.CommandLine") {
+		t.Error("--flagset=local registers flags on pflag.CommandLine")
+	}
+	writeHandler(t, app, "serve", `other := NewServeCommand()
+if err := other.Parse([]string{"--port=2"}); err != nil {
+	return err
+}
+fmt.Println(args.Port, other.Port)`)
+	bin := buildCommand(t, app)
+
+	if out, err := runCommand(bin, "--port=1"); err != nil || out != "1 2\n" {
+		t.Errorf("two commands in one process gave %q, %v, want \"1 2\\n\"", out, err)
+	}
+	if out, err := runCommand(bin, "--foo"); err == nil || !strings.Contains(out, "unknown flag: --foo. Run 'serve --help' for usage") {
+		t.Errorf("--foo gave %v, want an error pointing at the help:\n%s", err, out)
+	}
+}
+
+func TestOptionsWithSpaces(t *testing.T) {
+	dir := generate(t, `package main
+
+type DeployArgs struct {
+	Region string `+"`cli:\"region,options:\\\"North America\\\"|\\\"a,b\\\"|Europe\"`"+`
+}
+`, "deploy", "Deploys")
+	app := filepath.Join(dir, "cmd", "deploy")
+	writeHandler(t, app, "deploy", `fmt.Println(args.Region)`)
+	bin := buildCommand(t, app)
+
+	for _, region := range []string{"North America", "a,b", "Europe"} {
+		if out, err := runCommand(bin, "--region="+region); err != nil || out != region+"\n" {
+			t.Errorf("--region=%s gave %q, %v", region, out, err)
+		}
+	}
+	if out, err := runCommand(bin, "--region=North"); err == nil || !strings.Contains(out, "must be one of") {
+		t.Errorf("--region=North gave %v, want it rejected:\n%s", err, out)
+	}
+}
+
+func TestHelpWidth(t *testing.T) {
+	dir := generate(t, `package main
+
+type ServeArgs struct {
+	Root string `+"`cli:\"root,usage:Directory to serve the files from which must exist and be readable by the user running the server\"`"+`
+}
+`, "--help-width=60", "serve", "Starts an http server")
+	bin := buildCommand(t, filepath.Join(dir, "cmd", "serve"))
+
+	out, _ := runCommand(bin, "--help")
+	if !strings.Contains(out, "readable") {
+		t.Fatalf("--help lacks the usage of --root:\n%s", out)
+	}
+	if !strings.Contains(out, "\n                      from") {
+		t.Errorf("--help doesn't wrap the usage of --root:\n%s", out)
+	}
+	for _, line := range strings.Split(out, "\n") {
+		if strings.Contains(line, "--root") || strings.HasPrefix(line, "      ") {
+			if len(line) > 60 {
+				t.Errorf("--help line wider than 60 columns: %q", line)
+			}
+		}
+	}
+}
+
+func TestAliases(t *testing.T) {
+	dir := generate(t, `package main
+
+type BuildArgs struct {
+	Out string `+"`cli:\"out,o,alias:output|dest\"`"+`
+}
+`, "build", "Builds the site")
+	app := filepath.Join(dir, "cmd", "build")
+	writeHandler(t, app, "build", `fmt.Println(args.Out)`)
+	bin := buildCommand(t, app)
+
+	for _, flag := range []string{"--out", "-o", "--output", "--dest"} {
+		if out, err := runCommand(bin, flag, "site"); err != nil || out != "site\n" {
+			t.Errorf("%s site gave %q, %v, want \"site\\n\"", flag, out, err)
+		}
+	}
+	if out, _ := runCommand(bin, "--help"); strings.Contains(out, "--output") || strings.Contains(out, "--dest") {
+		t.Errorf("--help lists the aliases:\n%s", out)
+	}
+}
+
+func TestColorErrors(t *testing.T) {
+	dir := generate(t, `package main
+
+type ServeArgs struct {
+	Env string `+"`cli:\"env,required\"`"+`
+}
+`, "--with-color", "serve", "Starts an http server")
+	bin := buildCommand(t, filepath.Join(dir, "cmd", "serve"))
+
+	const red = "\x1b[31m"
+	for _, tt := range []struct {
+		color string
+		want  bool
+	}{
+		{"--color=always", true},
+		{"--color=never", false},
+		// stderr isn't a terminal here
+		{"--color=auto", false},
+	} {
+		out, err := runCommand(bin, tt.color)
+		if err == nil || !strings.Contains(out, "--env is required") {
+			t.Fatalf("%s gave %v, want a missing --env:\n%s", tt.color, err, out)
+		}
+		if strings.Contains(out, red) != tt.want {
+			t.Errorf("%s printed %q, want red %v", tt.color, out, tt.want)
+		}
+	}
+}
+
+func TestUsageSynonyms(t *testing.T) {
+	dir := writeFiles(t, map[string]string{"source.go": `package main
+
+type ServeArgs struct {
+	Port int    ` + "`cli:\"port,description:Port to listen on\"`" + `
+	Host string ` + "`cli:\"host,help:Host to bind\"`" + `
+	Root string ` + "`cli:\"root,usage:Files to serve,help:Ignored\"`" + `
+}
+`})
+	out := cligen(t, dir, "serve", "Starts an http server")
+	if !strings.Contains(out, "field Root: usage: is given, so description: and help: are ignored") {
+		t.Errorf("combining usage: and help: didn't warn:\n%s", out)
+	}
+	bin := buildCommand(t, filepath.Join(dir, "cmd", "serve"))
+
+	help, _ := runCommand(bin, "--help")
+	for _, want := range []string{"Port to listen on", "Host to bind", "Files to serve"} {
+		if !strings.Contains(help, want) {
+			t.Errorf("--help lacks %q:\n%s", want, help)
+		}
+	}
+	if strings.Contains(help, "Ignored") {
+		t.Errorf("--help shows the help: that usage: overrides:\n%s", help)
+	}
+}
+
+func TestCommandFromArgs(t *testing.T) {
+	dir := generate(t, `package main
+
+type ServeArgs struct {
+	Port int    `+"`cli:\"port,p,default:8080\"`"+`
+	Env  string `+"`cli:\"env,required,options:dev|prod\"`"+`
+}
+`, "serve", "Starts an http server")
+	app := filepath.Join(dir, "cmd", "serve")
+	test := `package main
+
+import "testing"
+
+func TestFromArgs(t *testing.T) {
+	cmd, err := NewServeCommandFromArgs([]string{"--port", "9090", "--env", "prod"})
+	if err != nil || cmd.Port != 9090 || cmd.Env != "prod" {
+		t.Fatalf("got %+v, %v", cmd, err)
+	}
+	if _, err := NewServeCommandFromArgs([]string{"--env=qa"}); err == nil {
+		t.Error("--env=qa was accepted")
+	}
+	if _, err := NewServeCommandFromArgs([]string{"--nope"}); err == nil {
+		t.Error("--nope was accepted")
+	}
+}
+`
+	if err := os.WriteFile(filepath.Join(app, "serve_test.go"), []byte(test), 0644); err != nil {
+		t.Fatal(err)
+	}
+	goTool(t, app, "test", ".")
+}
+
+func TestSeveralBackends(t *testing.T) {
+	dir := generate(t, serveSource, "--backend=pflag,stdflag,cobra", "serve", "Starts an http server")
+	for _, backend := range []string{"pflag", "stdflag", "cobra"} {
+		app := filepath.Join(dir, "cmd", "serve", backend)
+		writeHandler(t, app, "serve", `fmt.Println(args.Port)`)
+		bin := buildCommand(t, app)
+		if out, err := runCommand(bin, "--port=1"); err != nil || out != "1\n" {
+			t.Errorf("the %s command gave %q, %v, want \"1\\n\"", backend, out, err)
+		}
+	}
+	if code := readFile(t, filepath.Join(dir, "cmd", "serve", "cobra", "main.go")); !strings.Contains(code, "cobra.Command") {
+		t.Error("the cobra command doesn't use cobra")
+	}
+}
+
+func TestArgsMethod(t *testing.T) {
+	dir := generate(t, `package main
+
+type CpArgs struct {
+	Force bool   `+"`cli:\"force,f\"`"+`
+	Src   string `+"`cli:\"src,positional\"`"+`
+}
+`, "cp", "Copies files")
+	app := filepath.Join(dir, "cmd", "cp")
+	writeHandler(t, app, "cp", `fmt.Println(args.Src, args.Args())`)
+	bin := buildCommand(t, app)
+
+	if out, err := runCommand(bin, "a", "-f", "b", "c"); err != nil || out != "a [a b c]\n" {
+		t.Errorf("cp a -f b c gave %q, %v, want \"a [a b c]\\n\"", out, err)
+	}
+	generateFails(t, "package main\n\ntype CpArgs struct {\n\tArgs []string\n}\n", "field Args: clashes with the generated Args method", "cp", "Copies files")
+}
+
+func TestCountWithMax(t *testing.T) {
+	dir := generate(t, `package main
+
+type ServeArgs struct {
+	Verbose int `+"`cli:\"verbose,v,count,max:3\"`"+`
+}
+`, "serve", "Starts an http server")
+	app := filepath.Join(dir, "cmd", "serve")
+	writeHandler(t, app, "serve", `fmt.Println(args.Verbose)`)
+	bin := buildCommand(t, app)
+
+	for _, tt := range []struct {
+		args []string
+		want string
+	}{
+		{nil, "0\n"},
+		{[]string{"-v"}, "1\n"},
+		{[]string{"-v", "-v"}, "2\n"},
+		{[]string{"-vvvvv"}, "3\n"},
+	} {
+		if out, err := runCommand(bin, tt.args...); err != nil || out != tt.want {
+			t.Errorf("%v gave %q, %v, want %q", tt.args, out, err, tt.want)
+		}
+	}
+	generateFails(t, "package main\n\ntype ServeArgs struct {\n\tVerbose int `cli:\"verbose,v,count\"`\n}\n", "count is not supported by the stdflag backend", "--backend=stdflag", "serve", "Starts an http server")
+}
+
+func TestOutputIsFormatted(t *testing.T) {
+	dir := generate(t, `package main
+
+type ServeArgs struct {
+	Port int    `+"`cli:\"port,p,default:8080,validate\"`"+`
+	Env  string `+"`cli:\"env,required,options:dev|prod\"`"+`
+}
+`, "--with-color", "--with-completion", "serve", "Starts an http server")
+	paths, err := filepath.Glob(filepath.Join(dir, "cmd", "serve", "*.go"))
+	if err != nil || len(paths) < 4 {
+		t.Fatalf("generated %v, %v", paths, err)
+	}
+	for _, path := range paths {
+		code := readFile(t, path)
+		if formatted, err := format.Source([]byte(code)); err != nil || string(formatted) != code {
+			t.Errorf("%s isn't gofmt'ed (%v)", path, err)
+		}
+	}
+}
+
+func TestUnknownFlagsRejected(t *testing.T) {
+	for _, tt := range []struct {
+		args []string
+		want string
+	}{
+		{nil, "unknown flag: --nope"},
+		{[]string{"--flagset=local"}, "unknown flag: --nope. Run 'serve --help' for usage"},
+		{[]string{"--backend=stdflag"}, "flag provided but not defined: -nope"},
+	} {
+		dir := generate(t, serveSource, append(tt.args, "serve", "Starts an http server")...)
+		bin := buildCommand(t, filepath.Join(dir, "cmd", "serve"))
+		if out, err := runCommand(bin, "--nope"); err == nil || !strings.Contains(out, tt.want) {
+			t.Errorf("%v: --nope gave %v, want %q:\n%s", tt.args, err, tt.want, out)
+		}
+	}
+}
+
+func TestNoMain(t *testing.T) {
+	dir := generate(t, serveSource, "--no-main", "--flagset=local", "serve", "Starts an http server")
+	app := filepath.Join(dir, "cmd", "serve")
+	code := readFile(t, filepath.Join(app, "main.go"))
+	if strings.Contains(code, "func main()") || !strings.Contains(code, "package main") {
+		t.Fatal("--no-main kept func main or dropped package main")
+	}
+	main := "package main\n\nimport (\n\t\"fmt\"\n\t\"os\"\n)\n\nfunc main() {\n\tcmd := NewServeCommand()\n\tfmt.Println(cmd.Parse(os.Args[1:]), cmd.Port)\n}\n"
+	if err := os.WriteFile(filepath.Join(app, "app.go"), []byte(main), 0644); err != nil {
+		t.Fatal(err)
+	}
+	bin := buildCommand(t, app)
+	if out, err := runCommand(bin, "-p", "1"); err != nil || out != "<nil> 1\n" {
+		t.Errorf("a main of its own gave %q, %v, want \"<nil> 1\\n\"", out, err)
+	}
+
+	generateFails(t, subcommandsSource, "--no-main cannot be combined with --subcommands", "--no-main", "--subcommands=app")
+}
+
+func TestKeyedNames(t *testing.T) {
+	dir := generate(t, `package main
+
+type ServeArgs struct {
+	Retries int    `+"`cli:\"long:max-retries,short:r,default:3\"`"+`
+	Name    string `+"`cli:\"name,short:n\"`"+`
+	Host    string `+"`cli:\"default:localhost,long:bind\"`"+`
+}
+`, "serve", "Starts an http server")
+	app := filepath.Join(dir, "cmd", "serve")
+	writeHandler(t, app, "serve", `fmt.Println(args.Retries, args.Name, args.Host)`)
+	bin := buildCommand(t, app)
+
+	if out, err := runCommand(bin, "-r", "5", "-n", "x"); err != nil || out != "5 x localhost\n" {
+		t.Errorf("-r 5 -n x gave %q, %v, want \"5 x localhost\\n\"", out, err)
+	}
+	if out, err := runCommand(bin, "--max-retries=1", "--bind=::"); err != nil || out != "1  ::\n" {
+		t.Errorf("--max-retries=1 --bind=:: gave %q, %v, want \"1  ::\\n\"", out, err)
+	}
+}
+
+func TestHeaderFile(t *testing.T) {
+	const license = "// Copyright 2026 Example Ltd.\n// SPDX-License-Identifier: MIT\n"
+	for position, want := range map[string]string{
+		"before": license + "\n// Code generated by cligen. DO NOT EDIT.\n",
+		"after":  "//cligen:source source.go\n\n" + license + "\npackage main\n",
+	} {
+		dir := writeFiles(t, map[string]string{"source.go": serveSource, "LICENSE.txt": license})
+		cligen(t, dir, "--header-file=LICENSE.txt", "--header-position="+position, "serve", "Starts an http server")
+		if code := readFile(t, filepath.Join(dir, "cmd", "serve", "main.go")); !strings.Contains(code, want) {
+			t.Errorf("--header-position=%s lacks %q:\n%s", position, want, code)
+		}
+		buildCommand(t, filepath.Join(dir, "cmd", "serve"))
+	}
+
+	dir := writeFiles(t, map[string]string{"source.go": serveSource, "LICENSE.txt": "Copyright 2026\n"})
+	if out, err := runCligen(dir, "--header-file=LICENSE.txt", "serve", "Starts an http server"); err == nil || !strings.Contains(out, "must contain only Go comments") {
+		t.Errorf("a header that isn't comments gave %v:\n%s", err, out)
+	}
+}
+
+func TestRegenerationIsIdentical(t *testing.T) {
+	source := `package main
+
+type TLSConfig struct {
+	Cert string
+	Key  string
+}
+
+type ServeArgs struct {
+	TLS   *TLSConfig
+	Port  int               ` + "`cli:\"port,p,default:8080,validate\"`" + `
+	Env   string            ` + "`cli:\"env,required,options:dev|prod,env:APP_ENV\"`" + `
+	Retry struct{ Count int }
+}
+`
+	dir := writeFiles(t, map[string]string{"source.go": source})
+	args := []string{"--with-completion", "--with-color", "--stringer", "serve", "Starts an http server"}
+	cligen(t, dir, args...)
+	paths, _ := filepath.Glob(filepath.Join(dir, "cmd", "serve", "*"))
+	first := map[string]string{}
+	for _, path := range paths {
+		first[path] = readFile(t, path)
+	}
+
+	cligen(t, dir, args...)
+	for path, code := range first {
+		if again := readFile(t, path); again != code {
+			t.Errorf("regenerating changed %s", path)
+		}
+	}
+
+	// A struct's own fields come before the flags of its nested structs
+	help, _ := runCommand(buildCommand(t, filepath.Join(dir, "cmd", "serve")), "--help")
+	if port, cert := strings.Index(help, "--port"), strings.Index(help, "--tls-cert"); port < 0 || cert < port {
+		t.Errorf("--help doesn't list --port before --tls-cert:\n%s", help)
+	}
+}
+
+func TestParseCommand(t *testing.T) {
+	structName, fields, err := ParseCommand([]byte(`package main
+
+type ServeArgs struct {
+	Port int    `+"`cli:\"port,p,default:8080\"`"+`
+	Env  string `+"`cli:\"env,required,options:dev|prod\"`"+`
+}
+`), "serve")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if structName != "ServeArgs" || len(fields) != 2 {
+		t.Fatalf("got %s with %d fields, want ServeArgs with 2", structName, len(fields))
+	}
+	port, env := fields[0], fields[1]
+	if port.CLIName != "port" || port.ShortFlag != "p" || port.Type != "int" || port.DefaultValue != "8080" {
+		t.Errorf("got port %+v", port)
+	}
+	if env.CLIName != "env" || !env.Required || strings.Join(env.Options, "|") != "dev|prod" {
+		t.Errorf("got env %+v", env)
+	}
+
+	// Nothing is written
+	if _, err := os.Stat("cmd"); !os.IsNotExist(err) {
+		t.Errorf("ParseCommand wrote output: %v", err)
+	}
+}
+
+func TestPlaceholders(t *testing.T) {
+	dir := generate(t, `package main
+
+type ServeArgs struct {
+	File string `+"`cli:\"file,placeholder:FILE,usage:Read from FILE\"`"+`
+	Port int    `+"`cli:\"port,placeholder:PORT,usage:Port to listen on\"`"+`
+}
+`, "serve", "Starts an http server")
+	bin := buildCommand(t, filepath.Join(dir, "cmd", "serve"))
+
+	out, _ := runCommand(bin, "--help")
+	for _, want := range []string{"--file FILE", "Read from FILE", "--port PORT", "Port to listen on (PORT)"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("--help lacks %q:\n%s", want, out)
+		}
+	}
+}
+
+func TestPerm(t *testing.T) {
+	dir := writeFiles(t, map[string]string{"source.go": serveSource})
+	for range 2 {
+		// The second run replaces the read-only file of the first
+		cligen(t, dir, "--perm=0444", "serve", "Starts an http server")
+	}
+	app := filepath.Join(dir, "cmd", "serve")
+	for name, read := range map[string]bool{"main.go": true, "serve_impl.go": false, "go.mod": false} {
+		info, err := os.Stat(filepath.Join(app, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := info.Mode().Perm() == 0444; got != read {
+			t.Errorf("%s has mode %v, want 0444 only for the regenerated files", name, info.Mode().Perm())
+		}
+	}
+}
+
+func TestHiddenDefault(t *testing.T) {
+	dir := generate(t, `package main
+
+type ServeArgs struct {
+	Token string `+"`cli:\"token,default:s3cret,hidden-default\"`"+`
+	Port  int    `+"`cli:\"port,default:8080\"`"+`
+}
+`, "serve", "Starts an http server")
+	app := filepath.Join(dir, "cmd", "serve")
+	writeHandler(t, app, "serve", `fmt.Println(args.Token)`)
+	bin := buildCommand(t, app)
+
+	help, _ := runCommand(bin, "--help")
+	if strings.Contains(help, "s3cret") || !strings.Contains(help, "(default 8080)") {
+		t.Errorf("--help shows the hidden default or hides the other:\n%s", help)
+	}
+	if out, err := runCommand(bin); err != nil || out != "s3cret\n" {
+		t.Errorf("the hidden default gave %q, %v, want it applied", out, err)
+	}
+}
+
+func TestWindowsStyle(t *testing.T) {
+	dir := generate(t, `package main
+
+type CopyArgs struct {
+	Port    int    `+"`cli:\"port\"`"+`
+	Verbose bool   `+"`cli:\"verbose\"`"+`
+	Src     string `+"`cli:\"src,positional\"`"+`
+}
+`, "--style=windows", "copy", "Copies files")
+	app := filepath.Join(dir, "cmd", "copy")
+	writeHandler(t, app, "copy", `fmt.Println(args.Port, args.Verbose, args.Src, args.Args())`)
+	bin := buildCommand(t, app)
+
+	if out, err := runCommand(bin, "/port:8080", "/verbose", "/tmp/file", "--", "/port:1"); err != nil || out != "8080 true /tmp/file [/tmp/file /port:1]\n" {
+		t.Errorf("Windows style flags gave %q, %v", out, err)
+	}
+	if out, _ := runCommand(bin, "/?"); !strings.Contains(out, "--verbose") {
+		t.Errorf("/? didn't print the help:\n%s", out)
+	}
+}
+
+func TestNoChecksGenerated(t *testing.T) {
+	dir := generate(t, serveSource, "serve", "Starts an http server")
+	code := readFile(t, filepath.Join(dir, "cmd", "serve", "main.go"))
+	if !strings.Contains(code, "Validate() error {\n\treturn nil\n}") || strings.Contains(code, `"errors"`) {
+		t.Errorf("a command with only optional flags got checks:\n%s", code)
+	}
+
+	dir = generate(t, "package main\n\ntype ServeArgs struct {\n\tEnv string `cli:\"env,required\"`\n}\n", "serve", "Starts an http server")
+	if code := readFile(t, filepath.Join(dir, "cmd", "serve", "main.go")); !strings.Contains(code, "--env is required") || !strings.Contains(code, `"errors"`) {
+		t.Errorf("a command with a required flag got no check:\n%s", code)
+	}
+}
+
+func TestWarnUnusedTagKeys(t *testing.T) {
+	dir := writeFiles(t, map[string]string{"source.go": `package main
+
+type ServeArgs struct {
+	Port int    ` + "`clii:\"port\"`" + `
+	Env  string ` + "`json:\"env\" cl:\"env\"`" + `
+}
+`})
+	out := cligen(t, dir, "--warn-unused-tag-keys", "serve", "Starts an http server")
+	for _, want := range []string{`field Port: tag key "clii" is ignored, did you mean cli?`, `field Env: tag key "cl" is ignored`} {
+		if !strings.Contains(out, want) {
+			t.Errorf("warnings lack %q:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, `"json"`) {
+		t.Errorf("json was reported:\n%s", out)
+	}
+	if out := cligen(t, dir, "serve", "Starts an http server"); strings.Contains(out, "is ignored") {
+		t.Errorf("tag keys were reported without --warn-unused-tag-keys:\n%s", out)
+	}
+}
+
+func TestPrintOutputs(t *testing.T) {
+	for _, tt := range []struct {
+		source string
+		args   []string
+		want   string
+	}{
+		{serveSource, []string{"serve", "Starts an http server"}, "cmd/serve/main.go\ncmd/serve/go.mod\n"},
+		{serveSource, []string{"--backend=pflag,cobra", "--with-color", "serve", "Starts an http server"}, "cmd/serve/pflag/main.go\ncmd/serve/pflag/color.go\ncmd/serve/pflag/go.mod\ncmd/serve/cobra/main.go\ncmd/serve/cobra/color.go\ncmd/serve/cobra/go.mod\n"},
+		{subcommandsSource, []string{"--subcommands=app"}, "cmd/app/serve.go\ncmd/app/build.go\ncmd/app/main.go\ncmd/app/go.mod\n"},
+	} {
+		dir := writeFiles(t, map[string]string{"source.go": tt.source})
+		if out := cligen(t, dir, append([]string{"--print-outputs"}, tt.args...)...); out != tt.want {
+			t.Errorf("%v printed %q, want %q", tt.args, out, tt.want)
+		}
+		if _, err := os.Stat(filepath.Join(dir, "cmd")); !os.IsNotExist(err) {
+			t.Errorf("%v wrote files: %v", tt.args, err)
+		}
+	}
+}
+
+func TestParallelParsing(t *testing.T) {
+	dir := generate(t, `package main
+
+// ServeArgs starts an http server
+type ServeArgs struct {
+	Port int    `+"`cli:\"port,p,default:8080\"`"+`
+	Env  string `+"`cli:\"env,options:dev|prod\"`"+`
+}
+
+// MigrateArgs migrates the database
+type MigrateArgs struct {
+	Steps  int  `+"`cli:\"steps,n,default:1\"`"+`
+	DryRun bool `+"`cli:\"dry-run\"`"+`
+}
+`, "--with-color", "--subcommands=app")
+	app := filepath.Join(dir, "cmd", "app")
+	test := `package main
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestParallel(t *testing.T) {
+	for i := 0; i < 8; i++ {
+		t.Run(fmt.Sprint(i), func(t *testing.T) {
+			t.Parallel()
+			color := []string{"always", "never"}[i%2]
+			if i%2 == 0 {
+				cmd, err := NewServeCommandFromArgs([]string{"--port", fmt.Sprint(9000 + i), "--color", color})
+				if err != nil || cmd.Port != 9000+i || cmd.Env != "" || cmd.color != color {
+					t.Errorf("serve: got %+v, %v", cmd, err)
+				}
+				if _, err := NewServeCommandFromArgs([]string{"--env=qa"}); err == nil {
+					t.Error("serve: --env=qa was accepted")
+				}
+				return
+			}
+			cmd, err := NewMigrateCommandFromArgs([]string{"-n", fmt.Sprint(i), "--dry-run", "--color", color})
+			if err != nil || cmd.Steps != i || !cmd.DryRun || cmd.color != color {
+				t.Errorf("migrate: got %+v, %v", cmd, err)
+			}
+			if _, err := NewMigrateCommandFromArgs([]string{"--port=9000"}); err == nil {
+				t.Error("migrate: serve's --port was accepted")
+			}
+		})
+	}
+}
+`
+	if err := os.WriteFile(filepath.Join(app, "app_test.go"), []byte(test), 0644); err != nil {
+		t.Fatal(err)
+	}
+	goTool(t, app, "test", "-race", ".")
+}
+
+func TestStructTags(t *testing.T) {
+	dir := generate(t, `package main
+
+type ServeArgs struct {
+	Port int `+"`cli:\"port,default:8080\"`"+`
+	TLS  struct {
+		Cert string
+	} `+"`cli:\"tls\"`"+`
+}
+`, "--struct-tags=json,yaml", "serve", "Starts an http server")
+	app := filepath.Join(dir, "cmd", "serve")
+	writeHandler(t, app, "serve", `out, err := json.Marshal(args)
+if err != nil {
+	return err
+}
+fmt.Println(string(out))`, "encoding/json")
+	bin := buildCommand(t, app)
+
+	if out, err := runCommand(bin, "--tls-cert=a.pem"); err != nil || out != `{"port":8080,"tls":{"cert":"a.pem"}}`+"\n" {
+		t.Errorf("--tls-cert=a.pem encoded as %q, %v", out, err)
+	}
+	if code := readFile(t, filepath.Join(app, "main.go")); !strings.Contains(code, "`json:\"port\" yaml:\"port\"`") {
+		t.Errorf("the Port field isn't tagged for both keys:\n%s", code)
+	}
+}
+
+func TestInteractive(t *testing.T) {
+	dir := generate(t, `package main
+
+type DeployArgs struct {
+	Env  string `+"`cli:\"env,required,options:dev|prod\"`"+`
+	Name string `+"`cli:\"name,required\"`"+`
+}
+`, "--interactive", "deploy", "Deploys the site")
+	app := filepath.Join(dir, "cmd", "deploy")
+	test := `package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestPromptRequired(t *testing.T) {
+	cmd := NewDeployCommand()
+	cmd.promptRequired(strings.NewReader("3\nqa\n2\n\n"))
+	if cmd.Env != "prod" || cmd.Name != "" {
+		t.Errorf("the answers gave %+v, want --env=prod and no --name", cmd)
+	}
+	if err := cmd.Validate(); err == nil || !strings.Contains(err.Error(), "--name") {
+		t.Errorf("Validate gave %v, want --name missing", err)
+	}
+}
+`
+	if err := os.WriteFile(filepath.Join(app, "deploy_test.go"), []byte(test), 0644); err != nil {
+		t.Fatal(err)
+	}
+	goTool(t, app, "test", ".")
+
+	bin := buildCommand(t, app)
+	if out, err := runCommand(bin, "--env=dev"); err == nil || !strings.Contains(out, "--name") {
+		t.Errorf("without a terminal --env=dev gave %v, want --name missing:\n%s", err, out)
+	}
+}
+
+func TestRegisterFlags(t *testing.T) {
+	dir := generate(t, subcommandsSource, "--subcommands=app")
+	app := filepath.Join(dir, "cmd", "app")
+	test := `package main
+
+import (
+	"testing"
+
+	"github.com/spf13/pflag"
+)
+
+func TestSharedFlagSet(t *testing.T) {
+	fs := pflag.NewFlagSet("app", pflag.ContinueOnError)
+	serve, build := &ServeCommand{}, &BuildCommand{}
+	serve.RegisterFlags(fs)
+	build.RegisterFlags(fs)
+	if err := fs.Parse([]string{"-p", "9090", "--out=site"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := serve.Validate(); err != nil || serve.Port != 9090 || build.Out != "site" {
+		t.Errorf("got %+v and %+v, %v", serve, build, err)
+	}
+}
+`
+	if err := os.WriteFile(filepath.Join(app, "app_test.go"), []byte(test), 0644); err != nil {
+		t.Fatal(err)
+	}
+	goTool(t, app, "test", ".")
+}
+
+func TestDefaultStyle(t *testing.T) {
+	source := `package main
+
+type ServeArgs struct {
+	Port    int    ` + "`cli:\"port,default:8080,usage:Port to listen on\"`" + `
+	Host    string ` + "`cli:\"host,default:localhost,usage:Host to bind\"`" + `
+	Verbose bool   ` + "`cli:\"verbose,usage:Log requests\"`" + `
+}
+`
+	for _, tt := range []struct {
+		style  string
+		want   []string
+		reject []string
+	}{
+		{"suffix", []string{"Port to listen on (default 8080)", `Host to bind (default "localhost")`}, []string{"(default: "}},
+		{"inline", []string{"Port to listen on (default: 8080)", "Host to bind (default: localhost)", "Log requests (default: false)"}, []string{"(default 8080)"}},
+		{"none", nil, []string{"default"}},
+	} {
+		dir := generate(t, source, "--default-style="+tt.style, "serve", "Starts an http server")
+		bin := buildCommand(t, filepath.Join(dir, "cmd", "serve"))
+		out, _ := runCommand(bin, "--help")
+		for _, want := range tt.want {
+			if !strings.Contains(out, want) {
+				t.Errorf("--default-style=%s: --help lacks %q:\n%s", tt.style, want, out)
+			}
+		}
+		for _, reject := range tt.reject {
+			if strings.Contains(out, reject) {
+				t.Errorf("--default-style=%s: --help has %q:\n%s", tt.style, reject, out)
+			}
+		}
+	}
+}
+
+func TestFieldsSubset(t *testing.T) {
+	source := `package main
+
+type ServeArgs struct {
+	Port  int    ` + "`cli:\"port,default:8080\"`" + `
+	Env   string ` + "`cli:\"env\"`" + `
+	Debug bool   ` + "`cli:\"debug\"`" + `
+	TLS   struct {
+		Cert string
+		Key  string
+	}
+}
+`
+	dir := generate(t, source, "serve", "Starts an http server", "--fields=port,Env")
+	bin := buildCommand(t, filepath.Join(dir, "cmd", "serve"))
+	out, _ := runCommand(bin, "--help")
+	for _, want := range []string{"--port", "--env"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("--fields=port,Env: --help lacks %s:\n%s", want, out)
+		}
+	}
+	for _, reject := range []string{"--debug", "--tls-cert"} {
+		if strings.Contains(out, reject) {
+			t.Errorf("--fields=port,Env: --help has %s:\n%s", reject, out)
+		}
+	}
+	if out, err := runCommand(bin, "--debug"); err == nil {
+		t.Errorf("the unselected --debug was accepted:\n%s", out)
+	}
+
+	dir = generate(t, source, "serve", "Starts an http server", "--fields=TLS")
+	bin = buildCommand(t, filepath.Join(dir, "cmd", "serve"))
+	if out, _ := runCommand(bin, "--help"); !strings.Contains(out, "--tls-cert") || !strings.Contains(out, "--tls-key") || strings.Contains(out, "--port") {
+		t.Errorf("--fields=TLS doesn't select exactly the TLS fields:\n%s", out)
+	}
+
+	generateFails(t, source, `--fields names "host", which is neither a field nor a flag of the args struct`, "serve", "Starts an http server", "--fields=port,host")
+}
+
+func TestMultiNameFields(t *testing.T) {
+	dir := generate(t, `package main
+
+type ServeArgs struct {
+	Host, Bind string
+	Retry      struct {
+		Count, Delay int
+	}
+}
+`, "serve", "Starts an http server")
+	app := filepath.Join(dir, "cmd", "serve")
+	writeHandler(t, app, "serve", `fmt.Println(args.Host, args.Bind, args.Retry.Count, args.Retry.Delay)`)
+	bin := buildCommand(t, app)
+
+	out, err := runCommand(bin, "--host=h", "--bind=b", "--retry-count=3", "--retry-delay=5")
+	if err != nil || out != "h b 3 5\n" {
+		t.Errorf("a flag per name gave %q, %v, want \"h b 3 5\\n\"", out, err)
+	}
+}
+
+func TestNameStyle(t *testing.T) {
+	source := `package main
+
+type ServeArgs struct {
+	MaxRetries int
+	Port       int ` + "`cli:\"listen_port\"`" + `
+	TLS        struct {
+		CertFile string
+	}
+}
+`
+	for _, tt := range []struct {
+		style string
+		want  []string
+	}{
+		{"kebab", []string{"--max-retries", "--tls-cert-file", "--listen_port"}},
+		{"snake", []string{"--max_retries", "--tls_cert_file", "--listen_port"}},
+		{"camel", []string{"--maxRetries", "--tlsCertFile", "--listen_port"}},
+	} {
+		dir := generate(t, source, "--name-style="+tt.style, "serve", "Starts an http server")
+		bin := buildCommand(t, filepath.Join(dir, "cmd", "serve"))
+		out, _ := runCommand(bin, "--help")
+		for _, want := range tt.want {
+			if !strings.Contains(out, want+" ") {
+				t.Errorf("--name-style=%s: --help lacks %s:\n%s", tt.style, want, out)
+			}
+		}
+	}
+
+	generateFails(t, source, `Unknown name style "pascal"`, "--name-style=pascal", "serve", "Starts an http server")
+}
+
+func TestFieldOrder(t *testing.T) {
+	source := `package main
+
+type CopyArgs struct {
+	Verbose bool   ` + "`cli:\"verbose\"`" + `
+	Config  string ` + "`cli:\"config,order:-10\"`" + `
+	Force   bool   ` + "`cli:\"force,order:1\"`" + `
+	Dst     string ` + "`cli:\"dst,positional,order:1\"`" + `
+	Src     string ` + "`cli:\"src,positional\"`" + `
+}
+`
+	dir := generate(t, source, "copy", "Copies a file")
+	app := filepath.Join(dir, "cmd", "copy")
+	writeHandler(t, app, "copy", `fmt.Println(args.Src, args.Dst)`)
+	bin := buildCommand(t, app)
+
+	if out, err := runCommand(bin, "a", "b"); err != nil || out != "a b\n" {
+		t.Errorf("copy a b gave %q, %v, want src before dst", out, err)
+	}
+	out, _ := runCommand(bin, "--help")
+	if c, v, f := strings.Index(out, "--config"), strings.Index(out, "--verbose"), strings.Index(out, "--force"); c < 0 || c > v || v > f {
+		t.Errorf("--help doesn't list --config, --verbose, --force in order:\n%s", out)
+	}
+
+	dir = generate(t, source, "--sort-flags", "copy", "Copies a file")
+	out, _ = runCommand(buildCommand(t, filepath.Join(dir, "cmd", "copy")), "--help")
+	if c, f, v := strings.Index(out, "--config"), strings.Index(out, "--force"), strings.Index(out, "--verbose"); c > f || f > v {
+		t.Errorf("--sort-flags doesn't list the flags alphabetically:\n%s", out)
+	}
+}
+
+func TestTrace(t *testing.T) {
+	dir := writeFiles(t, map[string]string{"source.go": serveSource})
+	out := cligen(t, dir, "--trace", "serve", "Starts an http server")
+	for _, phase := range []string{"parse source.go", "discovery serve", "fields serve", "render cmd/serve/main.go", "format cmd/serve/main.go", "generate source.go"} {
+		if !regexp.MustCompile(`trace: ` + regexp.QuoteMeta(phase) + `: [0-9.]+[µnm]?s\n`).MatchString(out) {
+			t.Errorf("--trace doesn't time %s:\n%s", phase, out)
+		}
+	}
+	if out := cligen(t, dir, "serve", "Starts an http server"); strings.Contains(out, "trace:") {
+		t.Errorf("the phases were timed without --trace:\n%s", out)
+	}
+}
+
+func TestAddToCobraRoot(t *testing.T) {
+	dir := generate(t, serveSource, "--backend=cobra", "--no-main", "serve", "Starts an http server")
+	app := filepath.Join(dir, "cmd", "serve")
+	writeHandler(t, app, "serve", `fmt.Println("serving on", args.Port)`)
+	root := `package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+func main() {
+	root := &cobra.Command{Use: "app"}
+	AddServeTo(root)
+	if err := root.Execute(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+`
+	if err := os.WriteFile(filepath.Join(app, "root.go"), []byte(root), 0644); err != nil {
+		t.Fatal(err)
+	}
+	bin := buildCommand(t, app)
+
+	if out, err := runCommand(bin, "serve", "-p", "9090"); err != nil || out != "serving on 9090\n" {
+		t.Errorf("app serve -p 9090 gave %q, %v, want \"serving on 9090\\n\"", out, err)
+	}
+	if out, _ := runCommand(bin, "help"); !strings.Contains(out, "serve") || !strings.Contains(out, "Starts an http server") {
+		t.Errorf("app help doesn't list serve:\n%s", out)
+	}
+	if out, err := runCommand(bin, "serve", "--port=many"); err == nil || !strings.Contains(out, "app serve --help") {
+		t.Errorf("app serve --port=many gave %v, want a hint at app serve --help:\n%s", err, out)
+	}
+}
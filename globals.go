@@ -0,0 +1,115 @@
+package main
+
+import (
+	"fmt"
+	"go/ast"
+	"slices"
+	"strings"
+)
+
+// globalsField is the field of every subcommand holding the global flags
+const globalsField = "Globals"
+
+// isGlobal reports whether the doc comment of a struct marks it with
+// //cligen:global as the flags shared by every subcommand
+func (g *Generator) isGlobal(structName string) bool {
+	doc := g.docs[structName]
+	if doc == nil {
+		return false
+	}
+	for _, comment := range doc.List {
+		if strings.TrimSpace(comment.Text) == "//cligen:global" {
+			return true
+		}
+	}
+	return false
+}
+
+// parseGlobals returns the fields of the //cligen:global struct, or nil
+// when the source file has none. The preset and --output stay flags of
+// each command rather than being added twice.
+func (g *Generator) parseGlobals() ([]FieldInfo, map[string]string, error) {
+	var names []string
+	for name := range g.structs {
+		if g.isGlobal(name) {
+			names = append(names, name)
+		}
+	}
+	slices.Sort(names)
+	if len(names) == 0 {
+		return nil, nil, nil
+	}
+	if len(names) > 1 {
+		return nil, nil, fmt.Errorf("only one struct can be marked //cligen:global, found %s", strings.Join(names, ", "))
+	}
+
+	if err := g.checkGeneric(names[0]); err != nil {
+		return nil, nil, err
+	}
+
+	g.prefixes = make(map[string]string)
+	fields, err := g.collectFields(g.structs[names[0]], "", "", map[*ast.StructType]bool{})
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to parse global flags of %s: %w", names[0], err)
+	}
+	if err := g.resolveOptions(fields); err != nil {
+		return nil, nil, fmt.Errorf("failed to parse global flags of %s: %w", names[0], err)
+	}
+	fields = orderFields(fields)
+	for _, field := range fields {
+		if !field.IsFlag() {
+			return nil, nil, fmt.Errorf("failed to parse global flags of %s: %w", names[0], fieldErrorf(field, "global fields must be flags"))
+		}
+	}
+	if err := g.validateFields(fields); err != nil {
+		return nil, nil, fmt.Errorf("failed to parse global flags of %s: %w", names[0], err)
+	}
+
+	g.logf("global flags from struct %s", names[0])
+	return fields, g.prefixes, nil
+}
+
+// withGlobals nests the global fields under the Globals field of a
+// command, keeping their flag names. Like a preset, a command field taking
+// the name or a flag of a global one is an error rather than an override.
+func (g *Generator) withGlobals(fields, globals []FieldInfo, prefixes map[string]string) ([]FieldInfo, error) {
+	if len(globals) == 0 {
+		return fields, nil
+	}
+
+	for _, field := range fields {
+		if strings.Split(field.Name, ".")[0] == globalsField {
+			return nil, fieldErrorf(field, "clashes with the %s field holding the //cligen:global flags", globalsField)
+		}
+		for _, global := range globals {
+			var clash string
+			switch {
+			case !field.IsFlag():
+				continue
+			case field.CLIName == global.CLIName || slices.Contains(field.Aliases, global.CLIName) || slices.Contains(global.Aliases, field.CLIName):
+				clash = "--" + global.CLIName
+			case global.ShortFlag != "" && field.ShortFlag == global.ShortFlag:
+				clash = "-" + global.ShortFlag
+			default:
+				continue
+			}
+			return nil, fieldErrorf(field, "clashes with the global flag %s, rename one of them", clash)
+		}
+	}
+
+	g.prefixes[globalsField] = ""
+	for path, prefix := range prefixes {
+		g.prefixes[globalsField+"."+path] = prefix
+	}
+
+	for _, global := range globals {
+		global.Name = globalsField + "." + global.Name
+		var optional []string
+		for _, path := range global.Optional {
+			optional = append(optional, globalsField+"."+path)
+		}
+		global.Optional = optional
+		fields = append(fields, global)
+	}
+	return fields, nil
+}
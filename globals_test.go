@@ -0,0 +1,108 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const globalsSource = `package main
+
+//cligen:global
+type GlobalOptions struct {
+	Verbose bool   ` + "`cli:\"verbose,v,usage:Print more details\"`" + `
+	Quiet   bool   ` + "`cli:\"quiet,q\"`" + `
+	Config  string ` + "`cli:\"config,c\"`" + `
+}
+
+type ServeArgs struct {
+	Port int ` + "`cli:\"port,p,default:8080\"`" + `
+}
+
+type BuildArgs struct {
+	Out string ` + "`cli:\"out,o\"`" + `
+}
+`
+
+// globalsCommand generates the commands of globalsSource with handlers
+// printing the global flags they get and os.Args, and builds them
+func globalsCommand(t *testing.T) string {
+	t.Helper()
+	dir := writeFiles(t, map[string]string{"source.go": globalsSource})
+	cligen(t, dir, "--subcommands=app")
+	app := filepath.Join(dir, "cmd", "app")
+	for _, command := range []string{"Serve", "Build"} {
+		impl := `package main
+
+import (
+	"fmt"
+	"os"
+)
+
+func (c *` + command + `Command) ` + command + `Command(args *` + command + `Command) error {
+	fmt.Println(args.Globals.Verbose, os.Args[1:])
+	return nil
+}
+`
+		name := strings.ToLower(command) + "_impl.go"
+		if err := os.WriteFile(filepath.Join(app, name), []byte(impl), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return buildCommand(t, app)
+}
+
+func TestGlobalsBeforeTheCommand(t *testing.T) {
+	bin := globalsCommand(t)
+	for _, args := range [][]string{
+		{"-v", "serve", "--port=9000"},
+		{"--verbose", "build", "-o", "site"},
+	} {
+		out, err := runCommand(bin, args...)
+		if err != nil {
+			t.Fatalf("%v: %v\n%s", args, err, out)
+		}
+		// The command line the program was given is left as it was
+		if want := "true [" + strings.Join(args, " ") + "]\n"; out != want {
+			t.Errorf("%v printed %q, want %q", args, out, want)
+		}
+	}
+}
+
+func TestGlobalsMatchedLikePflag(t *testing.T) {
+	bin := globalsCommand(t)
+	for _, args := range [][]string{
+		{"-vq", "serve"},
+		{"-qvc", "app.yaml", "serve"},
+		{"-vc=app.yaml", "build"},
+	} {
+		out, err := runCommand(bin, args...)
+		if want := "true [" + strings.Join(args, " ") + "]\n"; err != nil || out != want {
+			t.Errorf("%v printed %q, %v, want %q", args, out, err, want)
+		}
+	}
+
+	// A shorthand isn't a long name, nor a long name a run of shorthands
+	for args, want := range map[string]string{
+		"--v serve":   "unknown global flag: --v",
+		"-port serve": "unknown shorthand flag: 'p' in -port",
+		"-vc":         "flag needs an argument: 'c' in -vc",
+		"--config":    "flag needs an argument: --config",
+	} {
+		out, err := runCommand(bin, strings.Fields(args)...)
+		if err == nil || !strings.Contains(out, want) {
+			t.Errorf("%s gave %q, %v, want a failure with %q", args, out, err, want)
+		}
+	}
+}
+
+func TestGlobalsUsageFallsBackToFlagName(t *testing.T) {
+	bin := globalsCommand(t)
+	out, _ := runCommand(bin, "--help")
+	for _, want := range []string{"--verbose  Print more details", "--config   config"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("--help lacks %q:\n%s", want, out)
+		}
+	}
+}
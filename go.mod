@@ -2,4 +2,13 @@ module github.com/almahoozi/cligen
 
 go 1.24.4
 
-require golang.org/x/text v0.26.0
+require (
+	golang.org/x/text v0.26.0
+	golang.org/x/tools v0.34.0
+	gopkg.in/yaml.v3 v3.0.1
+)
+
+require (
+	golang.org/x/mod v0.25.0 // indirect
+	golang.org/x/sync v0.15.0 // indirect
+)
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"strings"
+	"text/template"
+)
+
+// helpData is what a field's help may reference as a template, resolved
+// when generating, as in usage:Defaults to {{.Default}}
+type helpData struct {
+	Command string
+	Flag    string
+	Default string
+	Options []string
+}
+
+// expandHelp resolves the templates in the usage:, description: and help:
+// of the fields, so the generated help is a plain string that can't go
+// stale next to the default or options it repeats
+func expandHelp(command string, fields []FieldInfo) error {
+	for i := range fields {
+		field := &fields[i]
+		data := helpData{
+			Command: command,
+			Flag:    field.CLIName,
+			Default: field.DefaultValue,
+			Options: field.Options,
+		}
+
+		for _, help := range []*string{&field.Usage, &field.Help} {
+			if !strings.Contains(*help, "{{") {
+				continue
+			}
+
+			tmpl, err := template.New(field.Name).Option("missingkey=error").Funcs(template.FuncMap{
+				"join": strings.Join,
+			}).Parse(*help)
+			if err != nil {
+				return fieldErrorf(*field, "invalid help template: %v", err)
+			}
+			var b strings.Builder
+			if err := tmpl.Execute(&b, data); err != nil {
+				return fieldErrorf(*field, "invalid help template: %v", err)
+			}
+			*help = b.String()
+		}
+	}
+
+	return nil
+}
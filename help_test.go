@@ -0,0 +1,36 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestHelpTemplates(t *testing.T) {
+	dir := generate(t, `package main
+
+type ServeArgs struct {
+	Port int    `+"`cli:\"port,default:8080,usage:Port for {{.Command}} to listen on (defaults to {{.Default}})\"`"+`
+	Env  string `+"`cli:\"env,options:dev|prod,usage:Set --{{.Flag}} to one of {{.Options}}\"`"+`
+}
+`, "serve", "Starts an http server")
+	app := filepath.Join(dir, "cmd", "serve")
+	bin := buildCommand(t, app)
+
+	out, _ := runCommand(bin, "--help")
+	for _, want := range []string{"Port for serve to listen on (defaults to 8080)", "Set --env to one of [dev prod]"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("--help lacks %q:\n%s", want, out)
+		}
+	}
+	if code := readFile(t, filepath.Join(app, "main.go")); strings.Contains(code, "{{") {
+		t.Errorf("the generated help keeps a template:\n%s", code)
+	}
+
+	generateFails(t, `package main
+
+type ServeArgs struct {
+	Port int `+"`cli:\"port,usage:Listens on {{.Nope}}\"`"+`
+}
+`, "Port: invalid help template", "serve", "Starts an http server")
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// validators returns the fields that opted into a validation hook
+func validators(fields []FieldInfo) []FieldInfo {
+	var result []FieldInfo
+	for _, field := range fields {
+		if field.Validate {
+			result = append(result, field)
+		}
+	}
+	return result
+}
+
+// validatorName returns the name of the validation hook method of a field,
+// e.g. validateTLSCert for TLS.Cert
+func validatorName(field FieldInfo) string {
+	return "validate" + strings.ReplaceAll(field.Name, ".", "")
+}
+
+// generateValidationStubs writes a stub for every validation hook that is
+// not yet declared in the command's validation file. Existing hooks are
+// never touched, so the file can be edited freely.
+func (g *Generator) generateValidationStubs(command string, fields []FieldInfo) error {
+	hooks := validators(fields)
+	if len(hooks) == 0 {
+		return nil
+	}
+
+	path := filepath.Join(g.outputDir(), fmt.Sprintf("%s_validate.go", command))
+
+	if _, err := os.Stat(path); err != nil {
+		data := templateData{Command: command, Validators: hooks}
+		if err := g.renderTemplate("validate", path, data); err != nil {
+			return fmt.Errorf("failed to create validation file: %w", err)
+		}
+		return nil
+	}
+
+	// Only append the hooks missing from the existing file
+	node, err := parser.ParseFile(token.NewFileSet(), path, nil, 0)
+	if err != nil {
+		return fmt.Errorf("failed to parse validation file: %w", err)
+	}
+
+	declared := make(map[string]bool)
+	for _, decl := range node.Decls {
+		if fn, ok := decl.(*ast.FuncDecl); ok && fn.Recv != nil {
+			declared[fn.Name.Name] = true
+		}
+	}
+
+	var missing []FieldInfo
+	for _, field := range hooks {
+		if !declared[validatorName(field)] {
+			missing = append(missing, field)
+		}
+	}
+	if len(missing) == 0 || g.PrintOutputs {
+		return nil
+	}
+
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0)
+	if err != nil {
+		return fmt.Errorf("failed to open validation file: %w", err)
+	}
+	defer func() {
+		if closeErr := file.Close(); closeErr != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to close file %s: %v\n", path, closeErr)
+		}
+	}()
+
+	data := templateData{Command: command, Validators: missing, Append: true}
+	return g.executeTemplate("validate", file, data)
+}
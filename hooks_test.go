@@ -0,0 +1,54 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestValidationHooks(t *testing.T) {
+	dir := writeFiles(t, map[string]string{"source.go": `package main
+
+type ServeArgs struct {
+	Port int ` + "`cli:\"port,validate\"`" + `
+	Host string
+}
+`})
+	cligen(t, dir, "serve", "Starts an http server")
+	app := filepath.Join(dir, "cmd", "serve")
+	path := filepath.Join(app, "serve_validate.go")
+	if !strings.Contains(readFile(t, path), "func (c *ServeCommand) validatePort() error") {
+		t.Fatalf("no validatePort stub in %s", path)
+	}
+
+	hook := `package main
+
+import "errors"
+
+func (c *ServeCommand) validatePort() error {
+	if c.Port < 1024 {
+		return errors.New("must be 1024 or higher")
+	}
+	return nil
+}
+`
+	if err := os.WriteFile(path, []byte(hook), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// Tagging another field appends its stub and keeps the edited hook
+	if err := os.WriteFile(filepath.Join(dir, "source.go"), []byte(strings.Replace(readFile(t, filepath.Join(dir, "source.go")), "Host string", "Host string `cli:\"host,validate\"`", 1)), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cligen(t, dir, "serve", "Starts an http server")
+	hooks := readFile(t, path)
+	if !strings.HasPrefix(hooks, hook) || !strings.Contains(hooks, "func (c *ServeCommand) validateHost() error") {
+		t.Fatalf("regenerating didn't keep validatePort and add validateHost:\n%s", hooks)
+	}
+
+	bin := buildCommand(t, app)
+	if out, err := runCommand(bin, "--port=80"); err == nil || !strings.Contains(out, "--port: must be 1024 or higher") {
+		t.Errorf("--port=80 gave %v, want the hook's error:\n%s", err, out)
+	}
+}
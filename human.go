@@ -0,0 +1,47 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// humanUnits maps the suffixes of human sizes and counts, lower-cased, to
+// their multiplier. The binary ones only apply to sizes.
+var humanUnits = map[string]uint64{
+	"k": 1e3, "m": 1e6, "g": 1e9, "t": 1e12,
+	"ki": 1 << 10, "mi": 1 << 20, "gi": 1 << 30, "ti": 1 << 40,
+}
+
+// parseHuman parses a humansize value, such as 2MB or 2MiB, or a humancount
+// one, such as 10k, as the generated flag value does, to turn a default:
+// into a Go literal. The , and _ separating thousands are ignored.
+func parseHuman(s string, size bool) (uint64, error) {
+	text := strings.ToLower(strings.NewReplacer(",", "", "_", "").Replace(s))
+	if size {
+		text = strings.TrimSuffix(text, "b")
+	}
+	digits := strings.TrimRight(text, "kmgti")
+	multiplier := uint64(1)
+	if unit := text[len(digits):]; unit != "" {
+		var ok bool
+		if multiplier, ok = humanUnits[unit]; !ok || (!size && strings.HasSuffix(unit, "i")) {
+			return 0, fmt.Errorf("unknown unit in %q", s)
+		}
+	}
+	n, err := strconv.ParseUint(digits, 10, 64)
+	if err != nil || n > ^uint64(0)/multiplier {
+		return 0, fmt.Errorf("%q is not a number", s)
+	}
+	return n * multiplier, nil
+}
+
+// hasHumans reports whether any flag takes a human size or count
+func hasHumans(fields []FieldInfo) bool {
+	for _, field := range fields {
+		if field.Human != "" {
+			return true
+		}
+	}
+	return false
+}
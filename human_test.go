@@ -0,0 +1,44 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestHumanValues(t *testing.T) {
+	dir := generate(t, `package main
+
+type UploadArgs struct {
+	MaxSize int64  `+"`cli:\"max-size,humansize,default:1MiB\"`"+`
+	Limit   int    `+"`cli:\"limit,humancount\"`"+`
+	Small   uint16 `+"`cli:\"small,humansize\"`"+`
+}
+`, "upload", "Uploads files")
+	app := filepath.Join(dir, "cmd", "upload")
+	writeHandler(t, app, "upload", `fmt.Println(args.MaxSize, args.Limit, args.Small)`)
+	bin := buildCommand(t, app)
+
+	for _, tt := range []struct {
+		args []string
+		want string
+	}{
+		{nil, "1048576 0 0\n"},
+		{[]string{"--max-size=2MB"}, "2000000 0 0\n"},
+		{[]string{"--max-size=2MiB"}, "2097152 0 0\n"},
+		{[]string{"--limit=10k"}, "1048576 10000 0\n"},
+		{[]string{"--limit=10,000", "--small=1KiB"}, "1048576 10000 1024\n"},
+	} {
+		if out, err := runCommand(bin, tt.args...); err != nil || out != tt.want {
+			t.Errorf("%v gave %q, %v, want %q", tt.args, out, err, tt.want)
+		}
+	}
+	for _, args := range [][]string{{"--small=1MB"}, {"--max-size=2XB"}} {
+		if out, err := runCommand(bin, args...); err == nil {
+			t.Errorf("%v was accepted:\n%s", args, out)
+		}
+	}
+	if out, _ := runCommand(bin, "--help"); !strings.Contains(out, "1MiB") {
+		t.Errorf("--help doesn't show the default in MiB:\n%s", out)
+	}
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// implemented is the interface named by --implements, resolved to what the
+// generated code and its go.mod need to refer to it
+type implemented struct {
+	// Type is the interface as the generated code names it, as in
+	// plugin.Command
+	Type string
+	// Import imports the interface's package, and is nil for an interface
+	// declared next to the generated code
+	Import *ast.ImportSpec
+	// Module provides the package, and is nil for the standard library
+	Module *packages.Module
+}
+
+// resolveImplements loads the package of the --implements interface with
+// go/packages, resolved from the directory of the source file like --type,
+// so the generated module requires the same version of it as the source
+// module does
+func (g *Generator) resolveImplements() error {
+	if g.Implements == "" {
+		return nil
+	}
+
+	importPath, name := splitTypeName(g.Implements)
+	if !token.IsIdentifier(name) {
+		return fmt.Errorf("invalid --implements %q, expected [<import path>.]<interface> such as example.com/app/plugin.Command", g.Implements)
+	}
+	if importPath == "" {
+		g.implemented = &implemented{Type: name}
+		return nil
+	}
+
+	cfg := &packages.Config{
+		Mode: packages.NeedName | packages.NeedFiles | packages.NeedSyntax | packages.NeedModule,
+		Dir:  filepath.Dir(g.SourceFile),
+	}
+	pkgs, err := packages.Load(cfg, importPath)
+	if err != nil {
+		return fmt.Errorf("failed to load package %s: %w", importPath, err)
+	}
+	if len(pkgs) != 1 {
+		return fmt.Errorf("%s matched %d packages, expected one", importPath, len(pkgs))
+	}
+	pkg := pkgs[0]
+	if len(pkg.Errors) > 0 {
+		return fmt.Errorf("failed to load package %s: %w", importPath, pkg.Errors[0])
+	}
+	if pkg.Name == "main" {
+		return fmt.Errorf("--implements names package main %s, which can't be imported; give the interface without an import path to use one declared next to the generated code", pkg.PkgPath)
+	}
+	if !declaresInterface(pkg.Syntax, name) {
+		return fmt.Errorf("no interface type %s in package %s", name, pkg.PkgPath)
+	}
+
+	impl := &implemented{
+		Type:   pkg.Name + "." + name,
+		Import: &ast.ImportSpec{Path: &ast.BasicLit{Kind: token.STRING, Value: strconv.Quote(pkg.PkgPath)}},
+		Module: pkg.Module,
+	}
+	// The package name can't always be told from its path, as for a /v2
+	if importName(pkg.PkgPath) != pkg.Name {
+		impl.Import.Name = ast.NewIdent(pkg.Name)
+	}
+
+	g.logf("commands implement %s of package %s", impl.Type, pkg.PkgPath)
+	g.implemented = impl
+	return nil
+}
+
+// implementedType returns the interface the generated code asserts the
+// commands implement, or ""
+func (g *Generator) implementedType() string {
+	if g.implemented == nil {
+		return ""
+	}
+	return g.implemented.Type
+}
+
+// declaresInterface reports whether the files declare a type of the given
+// name that can be an interface. A type defined from another one, such as
+// type Command = other.Command, is left for the compiler to check.
+func declaresInterface(files []*ast.File, name string) bool {
+	for _, file := range files {
+		for _, decl := range file.Decls {
+			genDecl, ok := decl.(*ast.GenDecl)
+			if !ok || genDecl.Tok != token.TYPE {
+				continue
+			}
+			for _, spec := range genDecl.Specs {
+				typeSpec := spec.(*ast.TypeSpec)
+				if typeSpec.Name.Name != name {
+					continue
+				}
+				switch typeSpec.Type.(type) {
+				case *ast.InterfaceType, *ast.SelectorExpr, *ast.Ident:
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// moduleDirectives returns the go.mod directives requiring the module of
+// the --implements interface, if any. The source module itself and modules
+// replaced by a directory are replaced by their directory relative to the
+// generated go.mod.
+func (g *Generator) moduleDirectives() ([]string, error) {
+	if g.implemented == nil || g.implemented.Module == nil {
+		return nil, nil
+	}
+
+	mod := g.implemented.Module
+	if mod.Main {
+		dir, err := g.relativeDir(mod.Dir)
+		if err != nil {
+			return nil, err
+		}
+		// The source module has no version, so a placeholder is required
+		return []string{
+			"require " + mod.Path + " v0.0.0-00010101000000-000000000000",
+			"replace " + mod.Path + " => " + dir,
+		}, nil
+	}
+
+	directives := []string{"require " + mod.Path + " " + mod.Version}
+	switch replace := mod.Replace; {
+	case replace == nil:
+	case replace.Version != "":
+		directives = append(directives, "replace "+mod.Path+" => "+replace.Path+" "+replace.Version)
+	default:
+		dir, err := g.relativeDir(replace.Dir)
+		if err != nil {
+			return nil, err
+		}
+		directives = append(directives, "replace "+mod.Path+" => "+dir)
+	}
+	return directives, nil
+}
+
+// relativeDir returns dir relative to the output directory, in the form
+// go.mod takes for a directory, as in ../..
+func (g *Generator) relativeDir(dir string) (string, error) {
+	outputDir, err := filepath.Abs(g.outputDir())
+	if err != nil {
+		return "", err
+	}
+	rel, err := filepath.Rel(outputDir, dir)
+	if err != nil {
+		return "", fmt.Errorf("failed to locate module %s from %s: %w", dir, outputDir, err)
+	}
+	switch rel = filepath.ToSlash(rel); {
+	case rel == ".":
+		return "./", nil
+	case rel == ".." || strings.HasPrefix(rel, "../"):
+		return rel, nil
+	default:
+		return "./" + rel, nil
+	}
+}
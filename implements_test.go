@@ -0,0 +1,53 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestImplements(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"go.mod": "module example.com/app\n\ngo 1.24\n",
+		"source.go": `package main
+
+type ServeArgs struct {
+	Port int ` + "`cli:\"port,p,default:8080\"`" + `
+}
+
+func main() {}
+`,
+		"plugin/plugin.go": `package plugin
+
+type Command interface {
+	Execute() error
+}
+
+type Runner interface {
+	Run() error
+}
+`,
+	})
+	app := filepath.Join(dir, "cmd", "serve")
+
+	cligen(t, dir, "serve", "Starts an http server", "--implements=example.com/app/plugin.Command")
+	if code := readFile(t, filepath.Join(app, "main.go")); !strings.Contains(code, "var _ plugin.Command = (*ServeCommand)(nil)") {
+		t.Errorf("main.go lacks the assertion:\n%s", code)
+	}
+	buildCommand(t, app)
+
+	cligen(t, dir, "serve", "Starts an http server", "--implements=example.com/app/plugin.Runner")
+	cmd := exec.Command("go", "build", "-o", filepath.Join(app, "bin"), ".")
+	cmd.Dir = app
+	cmd.Env = append(os.Environ(), "GOFLAGS=-mod=mod", "GOPROXY=off", "GOSUMDB=off")
+	if out, err := cmd.CombinedOutput(); err == nil || !strings.Contains(string(out), "missing method Run") {
+		t.Errorf("building a command not implementing plugin.Runner gave %v, want a compile error:\n%s", err, out)
+	}
+
+	out, err := runCligen(dir, "serve", "Starts an http server", "--implements=example.com/app/plugin.Missing")
+	if err == nil || !strings.Contains(out, "no interface type Missing in package example.com/app/plugin") {
+		t.Errorf("an unknown interface gave %v, want it named:\n%s", err, out)
+	}
+}
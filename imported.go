@@ -0,0 +1,62 @@
+package main
+
+import (
+	"fmt"
+	"go/ast"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// splitTypeName splits a --type value such as example.com/app/config.ServeArgs
+// into the import path and the type name
+func splitTypeName(typeName string) (importPath, name string) {
+	i := strings.LastIndex(typeName, ".")
+	if i < 0 || i < strings.LastIndex(typeName, "/") {
+		return "", typeName
+	}
+	return typeName[:i], typeName[i+1:]
+}
+
+// loadType loads the package named by --type with go/packages, resolved from
+// the directory of the source file, and indexes the struct types of all its
+// files in place of the source file's. The package may be one whose source
+// isn't part of the module, such as a dependency in the module cache.
+func (g *Generator) loadType() (*ast.File, error) {
+	importPath, name := splitTypeName(g.Type)
+	if importPath == "" || name == "" {
+		return nil, fmt.Errorf("invalid --type %q, expected <import path>.<type> such as example.com/app/config.ServeArgs", g.Type)
+	}
+
+	cfg := &packages.Config{
+		Mode: packages.NeedName | packages.NeedFiles | packages.NeedSyntax,
+		Dir:  filepath.Dir(g.SourceFile),
+	}
+	pkgs, err := packages.Load(cfg, importPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load package %s: %w", importPath, err)
+	}
+	if len(pkgs) != 1 {
+		return nil, fmt.Errorf("%s matched %d packages, expected one", importPath, len(pkgs))
+	}
+	pkg := pkgs[0]
+	if len(pkg.Errors) > 0 {
+		return nil, fmt.Errorf("failed to load package %s: %w", importPath, pkg.Errors[0])
+	}
+	g.logf("loaded package %s from %d files", pkg.PkgPath, len(pkg.Syntax))
+
+	// The declarations of every file are merged so that nested structs and
+	// optionsfrom: declarations are found wherever they are declared
+	file := &ast.File{Name: ast.NewIdent(pkg.Name)}
+	for _, syntax := range pkg.Syntax {
+		file.Decls = append(file.Decls, syntax.Decls...)
+	}
+
+	g.file, g.fset = file, pkg.Fset
+	g.indexStructs(file)
+	if g.structs[name] == nil {
+		return nil, fmt.Errorf("no struct type %s in package %s", name, pkg.PkgPath)
+	}
+	return file, nil
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestTypeFromAnotherPackage(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"go.mod":  "module example.com/app\n\ngo 1.24\n",
+		"main.go": "package main\n\n//go:generate cligen serve \"Starts an http server\" --type=example.com/app/config.ServeArgs\nfunc main() {}\n",
+		"config/config.go": `package config
+
+type TLSConfig struct {
+	Cert string
+}
+
+type ServeArgs struct {
+	Port int ` + "`cli:\"port,p,default:8080\"`" + `
+	TLS  TLSConfig
+}
+`,
+	})
+	cmd := filepath.Join(dir, "cmd", "serve")
+	for _, typ := range []string{"example.com/app/config.ServeArgs", "./config.ServeArgs"} {
+		cligenFile(t, dir, "main.go", "serve", "Starts an http server", "--type="+typ)
+		writeHandler(t, cmd, "serve", `fmt.Println(args.Port, args.TLS.Cert)`)
+		bin := buildCommand(t, cmd)
+		if out, err := runCommand(bin, "--tls-cert=a.pem"); err != nil || out != "8080 a.pem\n" {
+			t.Errorf("--type=%s gave %q, %v, want \"8080 a.pem\\n\"", typ, out, err)
+		}
+	}
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"os"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Sentinels delimiting the code generated for a command in a file shared
+// by several commands, see --insert
+const (
+	beginSentinel = "// cligen:begin "
+	endSentinel   = "// cligen:end "
+)
+
+// insertBlock merges the code generated for a command into the file at
+// filename instead of replacing the file. The command's previous block, found
+// between its sentinels, is replaced in place, and other blocks and code are
+// kept. The imports of both are merged, dropping the ones no longer used.
+func (g *Generator) insertBlock(filename, command string, code []byte) ([]byte, error) {
+	fset := token.NewFileSet()
+	generated, err := parser.ParseFile(fset, "", code, parser.ParseComments|parser.SkipObjectResolution)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse the code generated for %s: %w", command, err)
+	}
+
+	head := fset.Position(generated.Name.End()).Offset
+	if len(generated.Imports) > 0 {
+		head = fset.Position(importsEnd(generated)).Offset
+	}
+	// Blank lines keep the sentinels out of the doc comments
+	block := beginSentinel + command + "\n\n" + strings.TrimSpace(string(code[head:])) + "\n\n" + endSentinel + command + "\n"
+
+	existing, err := os.ReadFile(filename)
+	if errors.Is(err, os.ErrNotExist) {
+		return []byte(string(code[:head]) + "\n\n" + block), nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", filename, err)
+	}
+	if !bytes.Contains(existing, []byte(beginSentinel)) {
+		if bytes.Contains(existing, []byte("// Code generated by cligen")) {
+			return nil, fmt.Errorf("%s was generated without --insert, delete it and generate each of its commands with --insert", filename)
+		}
+		return nil, fmt.Errorf("refusing to insert into %s, which was not generated by cligen", filename)
+	}
+
+	merged := string(existing)
+	begin := strings.Index(merged, beginSentinel+command+"\n")
+	end := strings.Index(merged, endSentinel+command+"\n")
+	switch {
+	case begin >= 0 && end > begin:
+		merged = merged[:begin] + block + merged[end+len(endSentinel+command+"\n"):]
+	case begin >= 0 || end >= 0:
+		return nil, fmt.Errorf("%s has an unterminated block for %s, fix its %q and %q comments", filename, command, beginSentinel+command, endSentinel+command)
+	default:
+		merged = strings.TrimRight(merged, "\n") + "\n\n" + block
+	}
+
+	return mergeImports(merged, generated.Imports)
+}
+
+// importsEnd returns the end of the last import declaration
+func importsEnd(file *ast.File) token.Pos {
+	var end token.Pos
+	for _, decl := range file.Decls {
+		if genDecl, ok := decl.(*ast.GenDecl); ok && genDecl.Tok == token.IMPORT {
+			end = genDecl.End()
+		}
+	}
+	return end
+}
+
+// mergeImports rewrites the imports of src to the union of its own and
+// extra, keeping only the packages src still refers to
+func mergeImports(src string, extra []*ast.ImportSpec) ([]byte, error) {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "", src, parser.ParseComments|parser.SkipObjectResolution)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse the merged file: %w", err)
+	}
+
+	used := map[string]bool{}
+	ast.Inspect(file, func(n ast.Node) bool {
+		if sel, ok := n.(*ast.SelectorExpr); ok {
+			if ident, ok := sel.X.(*ast.Ident); ok {
+				used[ident.Name] = true
+			}
+		}
+		return true
+	})
+
+	// Standard library packages are grouped above the others
+	var std, others []string
+	seen := map[string]bool{}
+	for _, spec := range append(file.Imports, extra...) {
+		importPath, _ := strconv.Unquote(spec.Path.Value)
+		name := importName(importPath)
+		line := spec.Path.Value
+		if spec.Name != nil {
+			name = spec.Name.Name
+			line = spec.Name.Name + " " + line
+		}
+		if seen[line] || (name != "_" && name != "." && !used[name]) {
+			continue
+		}
+		seen[line] = true
+		if first, _, _ := strings.Cut(importPath, "/"); strings.Contains(first, ".") {
+			others = append(others, line)
+		} else {
+			std = append(std, line)
+		}
+	}
+
+	var groups []string
+	for _, group := range [][]string{std, others} {
+		if len(group) > 0 {
+			slices.SortFunc(group, func(a, b string) int {
+				return strings.Compare(a[strings.Index(a, `"`):], b[strings.Index(b, `"`):])
+			})
+			groups = append(groups, strings.Join(group, "\n\t"))
+		}
+	}
+
+	var imports string
+	if len(groups) > 0 {
+		imports = "import (\n\t" + strings.Join(groups, "\n\n\t") + "\n)"
+	}
+
+	start, end := fset.Position(file.Name.End()).Offset, fset.Position(file.Name.End()).Offset
+	if len(file.Imports) > 0 {
+		start = fset.Position(firstImport(file)).Offset
+		end = fset.Position(importsEnd(file)).Offset
+	} else if imports != "" {
+		imports = "\n\n" + imports
+	}
+
+	return []byte(src[:start] + imports + src[end:]), nil
+}
+
+// firstImport returns the start of the first import declaration
+func firstImport(file *ast.File) token.Pos {
+	for _, decl := range file.Decls {
+		if genDecl, ok := decl.(*ast.GenDecl); ok && genDecl.Tok == token.IMPORT {
+			return genDecl.Pos()
+		}
+	}
+	return token.NoPos
+}
+
+// importName guesses the name of an imported package from its path, e.g.
+// yaml for gopkg.in/yaml.v3
+func importName(importPath string) string {
+	name, _, _ := strings.Cut(path.Base(importPath), ".")
+	return name
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const insertSource = `package main
+
+type ServeArgs struct {
+	Port int ` + "`cli:\"port,p,default:8080\"`" + `
+}
+
+type BuildArgs struct {
+	Out string ` + "`cli:\"out,o\"`" + `
+}
+`
+
+// insertCommands generates serve and build into one file with --insert
+func insertCommands(t *testing.T) string {
+	t.Helper()
+	dir := writeFiles(t, map[string]string{"source.go": insertSource})
+	for _, command := range []string{"serve", "build"} {
+		cligen(t, dir, "--insert", "--flagset=local", command, "Runs "+command, "cmd/app/commands.go")
+	}
+	return dir
+}
+
+func TestInsertLeavesOutMain(t *testing.T) {
+	dir := insertCommands(t)
+	app := filepath.Join(dir, "cmd", "app")
+	if strings.Contains(readFile(t, filepath.Join(app, "commands.go")), "func main()") {
+		t.Fatal("--insert generated func main")
+	}
+
+	main := `package main
+
+import (
+	"fmt"
+	"os"
+)
+
+func main() {
+	serve, err := NewServeCommandFromArgs(os.Args[1:])
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(2)
+	}
+	build, _ := NewBuildCommandFromArgs(nil)
+	fmt.Println(serve.Port, build.Out == "")
+}
+`
+	if err := os.WriteFile(filepath.Join(app, "main.go"), []byte(main), 0644); err != nil {
+		t.Fatal(err)
+	}
+	bin := buildCommand(t, app)
+	if out, err := runCommand(bin, "--port=9000"); err != nil || out != "9000 true\n" {
+		t.Errorf("running the commands gave %q, %v, want \"9000 true\\n\"", out, err)
+	}
+}
+
+func TestRegenRefusesInsertedFile(t *testing.T) {
+	dir := insertCommands(t)
+	out, err := runCligen(dir, "--regen", "cmd/app/commands.go")
+	if err == nil {
+		t.Fatalf("--regen of a file shared with --insert succeeded:\n%s", out)
+	}
+	if !strings.Contains(out, "--insert") {
+		t.Errorf("--regen failed without naming --insert:\n%s", out)
+	}
+}
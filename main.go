@@ -1,37 +1,433 @@
 package main
 
 import (
+	"encoding/json"
 	"fmt"
+	"go/parser"
+	"go/token"
 	"log"
+	"maps"
 	"os"
+	"slices"
+	"strconv"
 	"strings"
+	"unicode/utf8"
 )
 
 func main() {
-	if len(os.Args) < 2 {
-		printUsage()
-		os.Exit(1)
+	// Parse command line arguments
+	var verbose, trace, debugAST, quiet, printOutputs, interactive, stringer, strict, warnTagKeys, noFormat, noMain, insert, enumTypes, localFlags, windowsFlags, outputFormat, sortFlags, color, completion, writeConfig, readValidateTag bool
+	var helpWidth int
+	var perm os.FileMode
+	var program, modulePath, funcName, typeName, specFile, preset, implements, defaultStyle, nameStyle, binName string
+	var trimSuffixes, structTags []string
+	backend := "pflag"
+	format := "text"
+	var header, headerPosition string
+
+	argv := os.Args[1:]
+
+	if len(argv) == 1 && argv[0] == "--list-types" {
+		printTypes()
+		return
 	}
 
-	// Parse command line arguments
-	var command, help string
-	var outputFile string
+	// Re-run the invocation recorded in a generated file
+	var regenSource string
+	if len(argv) > 0 && (argv[0] == "--regen" || strings.HasPrefix(argv[0], "--regen=")) {
+		file := strings.TrimPrefix(argv[0], "--regen=")
+		argv = argv[1:]
+		if file == "--regen" {
+			if len(argv) == 0 {
+				log.Fatal("--regen requires a generated file")
+			}
+			file, argv = argv[0], argv[1:]
+		}
+
+		recorded, source, err := readGeneratedBy(file)
+		if err != nil {
+			log.Fatalf("Failed to read generation parameters: %v", err)
+		}
+		argv = append(recorded, argv...)
+		regenSource = source
+	}
+
+	// Pull out generator options so they work with both forms
+	var args []string
+	for _, arg := range argv {
+		switch {
+		case arg == "--verbose":
+			verbose = true
+		case arg == "--trace":
+			trace = true
+		case arg == "--debug-ast":
+			debugAST = true
+		case arg == "--quiet" || arg == "-q":
+			quiet = true
+		case arg == "--print-outputs":
+			printOutputs = true
+		case arg == "--no-main":
+			noMain = true
+		case arg == "--enum-types":
+			enumTypes = true
+		case arg == "--insert":
+			insert = true
+		case arg == "--no-format":
+			noFormat = true
+		case arg == "--strict":
+			strict = true
+		case arg == "--warn-unused-tag-keys":
+			warnTagKeys = true
+		case arg == "--stringer":
+			stringer = true
+		case arg == "--with-output-format":
+			outputFormat = true
+		case arg == "--interactive":
+			interactive = true
+		case arg == "--with-color":
+			color = true
+		case arg == "--with-completion":
+			completion = true
+		case arg == "--with-write-config":
+			writeConfig = true
+		case arg == "--read-validate-tag":
+			readValidateTag = true
+		case arg == "--sort-flags":
+			sortFlags = true
+		case strings.HasPrefix(arg, "--help-width="):
+			width, err := strconv.Atoi(strings.TrimPrefix(arg, "--help-width="))
+			if err != nil || width <= 0 {
+				log.Fatalf("Invalid --help-width %q, expected a positive number of columns", strings.TrimPrefix(arg, "--help-width="))
+			}
+			helpWidth = width
+		case strings.HasPrefix(arg, "--default-style="):
+			switch style := strings.TrimPrefix(arg, "--default-style="); style {
+			case "suffix":
+				defaultStyle = ""
+			case "inline", "none":
+				defaultStyle = style
+			default:
+				log.Fatalf("Unknown default style %q, expected inline, suffix or none", style)
+			}
+		case strings.HasPrefix(arg, "--bin-name="):
+			binName = strings.TrimPrefix(arg, "--bin-name=")
+		case strings.HasPrefix(arg, "--name-style="):
+			nameStyle = strings.TrimPrefix(arg, "--name-style=")
+			if !slices.Contains(nameStyles, nameStyle) {
+				log.Fatalf("Unknown name style %q, expected %s", nameStyle, strings.Join(nameStyles, ", "))
+			}
+		case strings.HasPrefix(arg, "--perm="):
+			mode, err := strconv.ParseUint(strings.TrimPrefix(arg, "--perm="), 8, 32)
+			if err != nil || mode == 0 || mode > 0777 {
+				log.Fatalf("Invalid --perm %q, expected an octal file mode such as 0644", strings.TrimPrefix(arg, "--perm="))
+			}
+			perm = os.FileMode(mode)
+		case strings.HasPrefix(arg, "--subcommands="):
+			program = strings.TrimPrefix(arg, "--subcommands=")
+		case strings.HasPrefix(arg, "--format="):
+			format = strings.TrimPrefix(arg, "--format=")
+			if format != "text" && format != "json" {
+				log.Fatalf("Unknown format %q, expected text or json", format)
+			}
+		case strings.HasPrefix(arg, "--header-file="):
+			path := strings.TrimPrefix(arg, "--header-file=")
+			content, err := os.ReadFile(path)
+			if err != nil {
+				log.Fatalf("Failed to read header file: %v", err)
+			}
+			if !onlyComments(string(content)) {
+				log.Fatalf("Header file %s must contain only Go comments", path)
+			}
+			header = string(content)
+		case strings.HasPrefix(arg, "--header-position="):
+			headerPosition = strings.TrimPrefix(arg, "--header-position=")
+			if headerPosition != "before" && headerPosition != "after" {
+				log.Fatalf("Unknown header position %q, expected before or after", headerPosition)
+			}
+		case strings.HasPrefix(arg, "--backend="):
+			backend = strings.TrimPrefix(arg, "--backend=")
+		case strings.HasPrefix(arg, "--struct-tags="):
+			for _, key := range strings.Split(strings.TrimPrefix(arg, "--struct-tags="), ",") {
+				if !token.IsIdentifier(key) {
+					log.Fatalf("Invalid --struct-tags key %q, expected names such as json,yaml", key)
+				}
+				structTags = append(structTags, key)
+			}
+		case strings.HasPrefix(arg, "--trim-suffix="):
+			trimSuffixes = append(trimSuffixes, strings.Split(strings.TrimPrefix(arg, "--trim-suffix="), ",")...)
+		case strings.HasPrefix(arg, "--module-path="):
+			modulePath = strings.TrimPrefix(arg, "--module-path=")
+		case strings.HasPrefix(arg, "--flagset="):
+			switch flagset := strings.TrimPrefix(arg, "--flagset="); flagset {
+			case "global":
+				localFlags = false
+			case "local":
+				localFlags = true
+			default:
+				log.Fatalf("Unknown flagset %q, expected global or local", flagset)
+			}
+		case strings.HasPrefix(arg, "--style="):
+			switch style := strings.TrimPrefix(arg, "--style="); style {
+			case "unix":
+				windowsFlags = false
+			case "windows":
+				windowsFlags = true
+			default:
+				log.Fatalf("Unknown style %q, expected unix or windows", style)
+			}
+		case strings.HasPrefix(arg, "--preset="):
+			preset = strings.TrimPrefix(arg, "--preset=")
+			if _, ok := presets[preset]; !ok {
+				log.Fatalf("Unknown preset %q, expected standard", preset)
+			}
+		case strings.HasPrefix(arg, "--func="):
+			funcName = strings.TrimPrefix(arg, "--func=")
+		case strings.HasPrefix(arg, "--type="):
+			typeName = strings.TrimPrefix(arg, "--type=")
+		case strings.HasPrefix(arg, "--spec="):
+			specFile = strings.TrimPrefix(arg, "--spec=")
+		case strings.HasPrefix(arg, "--implements="):
+			implements = strings.TrimPrefix(arg, "--implements=")
+		default:
+			args = append(args, arg)
+		}
+	}
+
+	// Several comma-separated backends are generated side by side
+	selected := strings.Split(backend, ",")
+	for _, name := range selected {
+		if !backends[name] {
+			log.Fatalf("Unknown backend %q", name)
+		}
+		if helpWidth != 0 && name != "pflag" {
+			log.Fatalf("--help-width is not supported by the %s backend", name)
+		}
+		if program != "" && name == "cobra" {
+			log.Fatal("--subcommands is not supported by the cobra backend")
+		}
+	}
+
+	if headerPosition != "" && header == "" {
+		log.Fatal("--header-position requires --header-file")
+	}
+
+	if insert && program != "" {
+		log.Fatal("--insert cannot be combined with --subcommands, which generates a file per command")
+	}
+	if insert {
+		// The commands sharing the file can't each declare main
+		noMain = true
+	}
+
+	if typeName != "" && (program != "" || funcName != "") {
+		log.Fatal("--type cannot be combined with --subcommands or --func, which read the source file")
+	}
+
+	if specFile != "" && (program != "" || funcName != "" || typeName != "") {
+		log.Fatal("--spec cannot be combined with --subcommands, --func or --type, which read Go source")
+	}
+
+	if binName != "" && program != "" {
+		log.Fatal("--bin-name cannot be combined with --subcommands, which names the program")
+	}
+	if strings.ContainsAny(binName, " \t'\"%") {
+		log.Fatalf("--bin-name %q must be a single word without quotes or %%", binName)
+	}
+
+	if noMain && program != "" {
+		log.Fatal("--no-main cannot be combined with --subcommands, whose main dispatches to the commands")
+	}
+
+	if program != "" || funcName != "" || specFile != "" {
+		for _, arg := range args {
+			if strings.HasPrefix(arg, "--fields=") {
+				log.Fatal("--fields cannot be combined with --subcommands, --func or --spec, it selects the fields of a command's args struct")
+			}
+		}
+	}
+
+	invs := []invocation{{}}
+	var spec *Spec
+	if specFile != "" {
+		if len(args) > 0 {
+			log.Fatalf("--spec takes the commands from the spec, unexpected arguments %v", args)
+		}
+		var err error
+		if spec, err = loadSpec(specFile); err != nil {
+			log.Fatal(err)
+		}
+		invs = nil
+		for _, cmd := range spec.Commands {
+			invs = append(invs, invocation{Command: cmd.Name, Help: cmd.Help, OutputFile: cmd.Output})
+		}
+	} else if funcName != "" && len(args) == 0 {
+		// The command is named after the function unless given explicitly
+		invs[0].Command = strings.ToLower(funcName)
+	} else if program == "" {
+		var ok bool
+		if invs, ok = parseInvocations(args); !ok {
+			printUsage()
+			os.Exit(1)
+		}
+
+		for _, inv := range invs {
+			if inv.Command == "" {
+				log.Fatal("Command name is required")
+			}
+		}
+		if len(invs) > 1 && funcName != "" {
+			log.Fatal("--func generates a single command, but several --command flags were given")
+		}
+	} else if len(args) > 0 {
+		invs[0].OutputFile = strings.TrimPrefix(args[0], "--output=")
+	}
+
+	for i, inv := range invs {
+		if inv.OutputFile == "" {
+			name := inv.Command
+			if program != "" {
+				name = program
+			}
+			invs[i].OutputFile = fmt.Sprintf("cmd/%s/main.go", name)
+		}
+	}
+
+	// Get the source file from GOFILE environment variable (set by go generate)
+	sourceFile := os.Getenv("GOFILE")
+	if specFile != "" {
+		sourceFile = specFile
+	}
+	if sourceFile == "" {
+		sourceFile = regenSource
+	}
+	if sourceFile == "" {
+		log.Fatal("GOFILE environment variable not set. This tool should be run via go generate")
+	}
+
+	// Parse the source file and generate CLI code
+	generator := &Generator{
+		SourceFile:   sourceFile,
+		Command:      invs[0].Command,
+		Help:         invs[0].Help,
+		OutputFile:   invs[0].OutputFile,
+		Fields:       invs[0].Fields,
+		More:         invs[1:],
+		Program:      program,
+		Backend:      selected[0],
+		Func:         funcName,
+		Type:         typeName,
+		Implements:   implements,
+		Spec:         spec,
+		Verbose:      verbose,
+		Trace:        trace,
+		DebugAST:     debugAST,
+		BinName:      binName,
+		Stringer:     stringer,
+		Strict:       strict,
+		WarnTagKeys:  warnTagKeys,
+		NoFormat:     noFormat,
+		NoMain:       noMain,
+		Insert:       insert,
+		EnumTypes:    enumTypes,
+		LocalFlags:   localFlags,
+		OutputFormat: outputFormat,
+		Preset:       preset,
+		Interactive:  interactive,
+		SortFlags:    sortFlags,
+		Color:        color,
+		Completion:   completion,
+		WriteConfig:  writeConfig,
+		HelpWidth:    helpWidth,
+		DefaultStyle: defaultStyle,
+		NameStyle:    nameStyle,
+		WindowsFlags: windowsFlags,
+		Perm:         perm,
+		PrintOutputs: printOutputs,
+		ModulePath:   modulePath,
+		TrimSuffixes: trimSuffixes,
+		StructTags:   structTags,
+		Header:       header,
+		HeaderAfter:  headerPosition == "after",
+		Invocation:   formatInvocation(argv),
+		// The validate tags are read by go-playground/validator
+		ReadValidateTag: readValidateTag,
+	}
+
+	if len(selected) > 1 {
+		generator.Backends = selected
+	}
+
+	if err := generator.Generate(); err != nil {
+		if format == "json" {
+			// Tools read a single JSON object from stderr
+			json.NewEncoder(os.Stderr).Encode(generator.Diagnose(err))
+			os.Exit(1)
+		}
+		log.Fatalf("Failed to generate CLI code: %v", err)
+	}
+
+	if printOutputs {
+		for _, path := range generator.Outputs {
+			fmt.Println(path)
+		}
+		return
+	}
+
+	if !quiet {
+		for _, backend := range generator.Backends {
+			for _, inv := range invs {
+				fmt.Printf("Generated %s CLI code in %s\n", backend, backendOutput(inv.OutputFile, backend))
+			}
+		}
+		if len(generator.Backends) == 0 {
+			for _, inv := range invs {
+				fmt.Printf("Generated CLI code in %s\n", inv.OutputFile)
+			}
+		}
+	}
+}
+
+// invocation holds the command arguments of a single cligen invocation
+type invocation struct {
+	Command    string
+	Help       string
+	OutputFile string
+	// Fields lists the fields given --fields, empty for all of them
+	Fields []string
+}
+
+// parseInvocations parses the command, help, output file and --fields from
+// either the long or the short argument form. The long form may name several
+// commands, each followed by its own --help, --output and --fields, and
+// --fields before the first command applies to all of them. It reports
+// false if the arguments are incomplete.
+func parseInvocations(args []string) ([]invocation, bool) {
+	if len(args) < 1 {
+		return nil, false
+	}
 
 	// Handle both long and short forms
-	if strings.HasPrefix(os.Args[1], "--command=") {
-		// Long form: --command=serve --help="description"
-		for i := 1; i < len(os.Args); i++ {
-			arg := os.Args[i]
+	var shared []string
+	first := 0
+	for ; first < len(args) && strings.HasPrefix(args[first], "--fields="); first++ {
+		shared = append(shared, splitFields(args[first])...)
+	}
+
+	if first < len(args) && strings.HasPrefix(args[first], "--command=") {
+		// Long form: --command=serve --help="description" [--command=...]
+		var invs []invocation
+		for i := first; i < len(args); i++ {
+			arg := args[i]
 			if strings.HasPrefix(arg, "--command=") {
-				command = strings.TrimPrefix(arg, "--command=")
+				invs = append(invs, invocation{Command: strings.TrimPrefix(arg, "--command="), Fields: slices.Clone(shared)})
 			} else if strings.HasPrefix(arg, "--help=") {
-				help = strings.TrimPrefix(arg, "--help=")
+				help := strings.TrimPrefix(arg, "--help=")
 				// Handle case where quoted argument is split across multiple args
 				if strings.HasPrefix(help, `"`) && !strings.HasSuffix(help, `"`) {
 					// Collect remaining parts until we find the closing quote
-					for j := i + 1; j < len(os.Args); j++ {
-						help += " " + os.Args[j]
-						if strings.HasSuffix(os.Args[j], `"`) {
+					for j := i + 1; j < len(args); j++ {
+						help += " " + args[j]
+						if strings.HasSuffix(args[j], `"`) {
 							i = j // Skip the args we've consumed
 							break
 						}
@@ -41,56 +437,162 @@ func main() {
 				if strings.HasPrefix(help, `"`) && strings.HasSuffix(help, `"`) {
 					help = strings.Trim(help, `"`)
 				}
+				invs[len(invs)-1].Help = help
 			} else if strings.HasPrefix(arg, "--output=") {
-				outputFile = strings.TrimPrefix(arg, "--output=")
+				invs[len(invs)-1].OutputFile = strings.TrimPrefix(arg, "--output=")
+			} else if strings.HasPrefix(arg, "--fields=") {
+				invs[len(invs)-1].Fields = append(invs[len(invs)-1].Fields, splitFields(arg)...)
 			}
 		}
-	} else {
-		// Short form: serve "description"
-		if len(os.Args) < 3 {
-			printUsage()
-			os.Exit(1)
-		}
-		command = os.Args[1]
-		help = os.Args[2]
-		if len(os.Args) > 3 {
-			outputFile = os.Args[3]
-		}
+		return invs, true
 	}
 
-	if command == "" {
-		log.Fatal("Command name is required")
+	// Short form: serve "description", with --fields anywhere
+	var fields, positional []string
+	for _, arg := range args {
+		if strings.HasPrefix(arg, "--fields=") {
+			fields = append(fields, splitFields(arg)...)
+		} else {
+			positional = append(positional, arg)
+		}
 	}
-
-	if outputFile == "" {
-		outputFile = fmt.Sprintf("cmd/%s/main.go", command)
+	if len(positional) < 2 {
+		return nil, false
 	}
-
-	// Get the source file from GOFILE environment variable (set by go generate)
-	sourceFile := os.Getenv("GOFILE")
-	if sourceFile == "" {
-		log.Fatal("GOFILE environment variable not set. This tool should be run via go generate")
+	inv := invocation{Command: positional[0], Help: positional[1], Fields: fields}
+	if len(positional) > 2 {
+		inv.OutputFile = positional[2]
 	}
 
-	// Parse the source file and generate CLI code
-	generator := &Generator{
-		SourceFile: sourceFile,
-		Command:    command,
-		Help:       help,
-		OutputFile: outputFile,
+	return []invocation{inv}, true
+}
+
+// splitFields splits the comma-separated names of a --fields argument
+func splitFields(arg string) []string {
+	var fields []string
+	for _, name := range strings.Split(strings.TrimPrefix(arg, "--fields="), ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			fields = append(fields, name)
+		}
 	}
+	return fields
+}
 
-	if err := generator.Generate(); err != nil {
-		log.Fatalf("Failed to generate CLI code: %v", err)
+// onlyComments reports whether s holds nothing but Go comments, so that it
+// can be placed above the package clause
+func onlyComments(s string) bool {
+	_, err := parser.ParseFile(token.NewFileSet(), "", s+"\npackage p\n", parser.PackageClauseOnly)
+	return err == nil
+}
+
+// splitArgs splits a command line into arguments, keeping double-quoted
+// sections together and dropping the quotes. A backslash in a quoted section
+// starts an escape as in a Go string, so formatInvocation's strconv.Quote
+// output and go:generate's quoting read back the same.
+func splitArgs(line string) []string {
+	var args []string
+	var current strings.Builder
+	inQuotes, inArg := false, false
+
+	for rest := line; rest != ""; {
+		r, size := utf8.DecodeRuneInString(rest)
+		switch {
+		case r == '\\' && inQuotes:
+			value, _, tail, err := strconv.UnquoteChar(rest, '"')
+			if err != nil {
+				// Not an escape, so the backslash is kept as written
+				current.WriteRune(r)
+				break
+			}
+			current.WriteRune(value)
+			rest = tail
+			continue
+		case r == '"':
+			inQuotes = !inQuotes
+			inArg = true
+		case (r == ' ' || r == '\t') && !inQuotes:
+			if inArg {
+				args = append(args, current.String())
+				current.Reset()
+				inArg = false
+			}
+		default:
+			current.WriteRune(r)
+			inArg = true
+		}
+		rest = rest[size:]
 	}
+	if inArg {
+		args = append(args, current.String())
+	}
+
+	return args
+}
 
-	fmt.Printf("Generated CLI code in %s\n", outputFile)
+// printTypes lists the field types cligen generates flags for, from the
+// same table the generator checks fields against
+func printTypes() {
+	fmt.Println("Supported field types:")
+	for _, name := range slices.Sorted(maps.Keys(typeBindings)) {
+		if stdflagSupports(name) {
+			fmt.Printf("  %s\n", name)
+		} else {
+			fmt.Printf("  %-10s (not as a flag with --backend=stdflag)\n", name)
+		}
+	}
 }
 
 func printUsage() {
 	fmt.Println("Usage:")
-	fmt.Println("  cligen --command=<name> --help=\"<description>\" [--output=<file>]")
+	fmt.Println("  cligen --command=<name> --help=\"<description>\" [--output=<file>] [--command=<name> ...]")
 	fmt.Println("  cligen <command> \"<description>\" [output_file]")
+	fmt.Println("  cligen --subcommands=<program> [--output=<file>]")
+	fmt.Println("  cligen --func=<name> [<command> \"<description>\" [output_file]]")
+	fmt.Println("  cligen --regen <generated_file> [options]")
+	fmt.Println("  cligen --list-types")
+	fmt.Println("  cligen --spec=<file> [options]")
+	fmt.Println()
+	fmt.Println("Options:")
+	fmt.Println("  --verbose              Log struct matching and field parsing details to stderr")
+	fmt.Println("  --trace                Log how long parsing, discovery, field parsing, rendering and formatting take to stderr")
+	fmt.Println("  --debug-ast            Print the type declarations of the source and the fields of each matched struct to stderr")
+	fmt.Println("  -q, --quiet            Don't print the success message")
+	fmt.Println("  --print-outputs        Print the paths of the files the invocation generates, one per line, without writing them")
+	fmt.Println("  --no-main              Leave out func main, keeping package main")
+	fmt.Println("  --insert               Merge the command into an existing generated file instead of replacing it (implies --no-main)")
+	fmt.Println("  --no-format            Write the generated code as rendered, without running gofmt")
+	fmt.Println("  --strict               Treat warnings about the struct tags as errors")
+	fmt.Println("  --warn-unused-tag-keys Warn about tag keys that look like a misspelled cli")
+	fmt.Println("  --struct-tags=<keys>   Tag the fields of the generated struct with their flag names, e.g. json,yaml")
+	fmt.Println("  --stringer             Generate String and GoString methods for the command")
+	fmt.Println("  --with-output-format   Add an --output json|yaml|text flag and a Render helper")
+	fmt.Println("  --preset=<name>        Add a set of common flags to every command: standard (--verbose, --quiet, --config)")
+	fmt.Println("  --interactive          Ask for missing required flags when stdin is a terminal instead of failing")
+	fmt.Println("  --with-color           Add a --color flag and print errors in red on terminals, honoring NO_COLOR")
+	fmt.Println("  --with-completion      Add a hidden --generate-completion=bash|zsh|fish flag writing a completion script")
+	fmt.Println("  --with-write-config    Add a --write-config=FILE flag writing a YAML file of every flag and its default")
+	fmt.Println("  --enum-types           Check options: while parsing, through a flag.Value listing the options")
+	fmt.Println("  --sort-flags           List flags alphabetically in the help instead of in declaration order")
+	fmt.Println("  --read-validate-tag    Turn the required, oneof=, min= and max= rules of validate tags into checks")
+	fmt.Println("  --help-width=<cols>    Wrap the flag help at the given column (pflag only)")
+	fmt.Println("  --default-style=<s>    Show defaults in the help as (default: X), as the flag package does (suffix) or not at all")
+	fmt.Println("  --bin-name=<name>      Program name shown in the usage line and --help hints instead of the command name")
+	fmt.Println("  --name-style=<s>       Derive flag names from field names as kebab (default), snake or camel case")
+	fmt.Println("  --perm=<mode>          Octal file mode of the generated files, e.g. 0444 (default 0644 before umask)")
+	fmt.Println("  --subcommands=<name>   Generate one program dispatching to every args struct in the file")
+	fmt.Println("  --backend=<names>      Flag package to generate for: pflag (default), stdflag or cobra; a comma list generates each")
+	fmt.Println("  --flagset=<mode>       Register flags on the global CommandLine (default) or a local FlagSet")
+	fmt.Println("  --style=<style>        Accept unix (default) --name=value flags, or also windows /name:value ones")
+	fmt.Println("  --func=<name>          Generate from the parameters of a function instead of a struct")
+	fmt.Println("  --type=<pkg>.<name>    Load the args struct from another package, e.g. example.com/app/config.ServeArgs")
+	fmt.Println("  --spec=<file>          Generate the commands described in a YAML or JSON spec instead of Go source")
+	fmt.Println("  --implements=<iface>   Assert that the commands implement an interface, e.g. example.com/app/plugin.Command")
+	fmt.Println("  --fields=<names>       Generate flags for only the named fields or flags of the args struct, e.g. port,env")
+	fmt.Println("  --trim-suffix=<names>  Struct name suffixes stripped to infer command names (default: CLIArgs,Args)")
+	fmt.Println("  --format=<format>      Report generation errors as text (default) or as a JSON object on stderr")
+	fmt.Println("  --header-file=<file>   Prepend the comments in the file, such as a license, to the generated files")
+	fmt.Println("  --header-position=<p>  Put the header before (default) or after the generated-code marker")
+	fmt.Println("  --module-path=<path>   Module path of the source package (default: read from go.mod)")
 	fmt.Println()
 	fmt.Println("This tool should be run via go generate with a comment like:")
 	fmt.Println("  //go:generate cligen serve \"Starts an http server\"")
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestMain runs the test binary as cligen itself when runCligen re-executes
+// it, so that the tests drive the real command line, flags and all
+func TestMain(m *testing.M) {
+	if os.Getenv("CLIGEN_TEST_MAIN") == "1" {
+		main()
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+// writeFiles writes files, keyed by their slash-separated path, into a new
+// temporary directory and returns it
+func writeFiles(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+// runCligen runs cligen in dir, as go generate would for source.go, and
+// returns what it printed
+func runCligen(dir string, args ...string) (string, error) {
+	return runCligenFile(dir, "source.go", args...)
+}
+
+// runCligenFile runs cligen in dir as go generate would for file
+func runCligenFile(dir, file string, args ...string) (string, error) {
+	cmd := exec.Command(os.Args[0], args...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "CLIGEN_TEST_MAIN=1", "GOFILE="+file)
+	out, err := cmd.CombinedOutput()
+	return string(out), err
+}
+
+// cligen runs cligen in dir and fails the test if it fails
+func cligen(t *testing.T, dir string, args ...string) string {
+	t.Helper()
+	return cligenFile(t, dir, "source.go", args...)
+}
+
+// cligenFile runs cligen in dir for file and fails the test if it fails
+func cligenFile(t *testing.T, dir, file string, args ...string) string {
+	t.Helper()
+	out, err := runCligenFile(dir, file, args...)
+	if err != nil {
+		t.Fatalf("cligen %v: %v\n%s", args, err, out)
+	}
+	return out
+}
+
+// buildCommand vets and builds the program generated into dir and returns
+// the binary
+func buildCommand(t *testing.T, dir string) string {
+	t.Helper()
+	bin := filepath.Join(dir, "bin")
+	goTool(t, dir, "vet", ".")
+	goTool(t, dir, "build", "-o", bin, ".")
+	return bin
+}
+
+// goTool runs the go command in the directory of a generated program,
+// resolving its dependencies from the module cache only, and returns what
+// it printed
+func goTool(t *testing.T, dir string, args ...string) string {
+	t.Helper()
+	cmd := exec.Command("go", args...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "GOFLAGS=-mod=mod", "GOPROXY=off", "GOSUMDB=off")
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("go %v in %s: %v\n%s", args, dir, err, out)
+	}
+	return string(out)
+}
+
+// runCommand runs a generated program and returns its combined output,
+// with the error of a non-zero exit
+func runCommand(bin string, args ...string) (string, error) {
+	return runCommandEnv(bin, nil, args...)
+}
+
+// runCommandEnv runs a generated program like runCommand, with env added
+// to the environment
+func runCommandEnv(bin string, env []string, args ...string) (string, error) {
+	cmd := exec.Command(bin, args...)
+	cmd.Env = append(os.Environ(), env...)
+	out, err := cmd.CombinedOutput()
+	return string(out), err
+}
+
+// readFile returns the contents of a generated file
+func readFile(t *testing.T, path string) string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+// generate writes source.go into a new temporary directory, runs cligen in
+// it with args and returns the directory
+func generate(t *testing.T, source string, args ...string) string {
+	t.Helper()
+	dir := writeFiles(t, map[string]string{"source.go": source})
+	cligen(t, dir, args...)
+	return dir
+}
+
+// writeHandler replaces the implementation stub of command, generated into
+// dir, with a handler running body, which can use fmt, the other imports
+// given and the command's args
+func writeHandler(t *testing.T, dir, command, body string, imports ...string) {
+	t.Helper()
+	name := upperFirst(command) + "Command"
+	impl := "package main\n\nimport (\n\t\"fmt\"\n"
+	for _, path := range imports {
+		impl += "\t\"" + path + "\"\n"
+	}
+	impl += ")\n\nvar _ = fmt.Sprint\n\n" +
+		"func (c *" + name + ") " + name + "(args *" + name + ") error {\n" + body + "\nreturn nil\n}\n"
+	if err := os.WriteFile(filepath.Join(dir, command+"_impl.go"), []byte(impl), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// generateFails runs cligen on source with args and fails the test unless
+// generation fails with an error containing want
+func generateFails(t *testing.T, source, want string, args ...string) {
+	t.Helper()
+	dir := writeFiles(t, map[string]string{"source.go": source})
+	out, err := runCligen(dir, args...)
+	if err == nil || !strings.Contains(out, want) {
+		t.Errorf("cligen %v gave %v, want an error containing %q:\n%s", args, err, want, out)
+	}
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// errNoModule is returned when no go.mod is found above a directory
+var errNoModule = errors.New("no go.mod found")
+
+// findModule walks up from dir to the nearest go.mod and returns the
+// module path it declares along with the directory containing it
+func findModule(dir string) (modulePath, moduleRoot string, err error) {
+	dir, err = filepath.Abs(dir)
+	if err != nil {
+		return "", "", err
+	}
+
+	for {
+		goModPath := filepath.Join(dir, "go.mod")
+		if _, err := os.Stat(goModPath); err == nil {
+			modulePath, err := readModulePath(goModPath)
+			if err != nil {
+				return "", "", err
+			}
+			return modulePath, dir, nil
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", "", errNoModule
+		}
+		dir = parent
+	}
+}
+
+// readModulePath returns the path from the module directive of a go.mod file
+func readModulePath(goModPath string) (string, error) {
+	file, err := os.Open(goModPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to open %s: %w", goModPath, err)
+	}
+	defer func() {
+		if closeErr := file.Close(); closeErr != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to close file %s: %v\n", goModPath, closeErr)
+		}
+	}()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		rest, ok := strings.CutPrefix(line, "module")
+		if !ok || (rest != "" && rest[0] != ' ' && rest[0] != '\t') {
+			continue
+		}
+
+		rest, _, _ = strings.Cut(rest, "//")
+		rest = strings.TrimSpace(rest)
+		if unquoted, err := strconv.Unquote(rest); err == nil {
+			rest = unquoted
+		}
+		if rest != "" {
+			return rest, nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return "", fmt.Errorf("failed to read %s: %w", goModPath, err)
+	}
+
+	return "", fmt.Errorf("no module directive in %s", goModPath)
+}
+
+// resolveModule determines the module of the source file, preferring an
+// explicit --module-path. Without either, the module is left empty.
+func (g *Generator) resolveModule() error {
+	sourceDir := filepath.Dir(g.SourceFile)
+
+	modulePath, moduleRoot, err := findModule(sourceDir)
+	if err != nil && !errors.Is(err, errNoModule) {
+		return err
+	}
+
+	if g.ModulePath != "" {
+		modulePath = g.ModulePath
+		if moduleRoot == "" {
+			if moduleRoot, err = filepath.Abs(sourceDir); err != nil {
+				return err
+			}
+		}
+	}
+
+	g.ModulePath, g.moduleRoot = modulePath, moduleRoot
+	if modulePath != "" {
+		g.logf("resolved module %s rooted at %s", modulePath, moduleRoot)
+	}
+
+	return nil
+}
+
+// importPath returns the import path of a directory inside the source
+// module, or "" if the module is unknown or dir lies outside it
+func (g *Generator) importPath(dir string) string {
+	if g.ModulePath == "" {
+		return ""
+	}
+
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return ""
+	}
+
+	rel, err := filepath.Rel(g.moduleRoot, abs)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return ""
+	}
+	if rel == "." {
+		return g.ModulePath
+	}
+
+	return path.Join(g.ModulePath, filepath.ToSlash(rel))
+}
@@ -0,0 +1,26 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestModulePath(t *testing.T) {
+	for _, tt := range []struct {
+		args []string
+		want string
+	}{
+		{nil, "module example.com/app/cmd/serve\n"},
+		{[]string{"--module-path=example.org/tools"}, "module example.org/tools/cmd/serve\n"},
+	} {
+		dir := writeFiles(t, map[string]string{
+			"go.mod":    "module example.com/app\n\ngo 1.24\n",
+			"source.go": serveSource,
+		})
+		cligen(t, dir, append(tt.args, "serve", "Starts an http server")...)
+		if mod := readFile(t, filepath.Join(dir, "cmd", "serve", "go.mod")); !strings.HasPrefix(mod, tt.want) {
+			t.Errorf("%v: go.mod starts %q, want %q", tt.args, mod, tt.want)
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// nameStyles lists the values of --name-style, the default first
+var nameStyles = []string{"kebab", "snake", "camel"}
+
+// flagName derives the flag name of a Go field or parameter without one in
+// its tag, in the --name-style: MaxRetries becomes max-retries, max_retries
+// or maxRetries.
+func (g *Generator) flagName(name string) string {
+	words := strings.Split(kebabCase(name), "-")
+	switch g.NameStyle {
+	case "snake":
+		return strings.Join(words, "_")
+	case "camel":
+		for i := 1; i < len(words); i++ {
+			words[i] = upperFirst(words[i])
+		}
+		return strings.Join(words, "")
+	}
+	return strings.Join(words, "-")
+}
+
+// joinFlagName prefixes the flag name of a nested field with the prefix of
+// the struct holding it, joined in the --name-style, as in tls-cert,
+// tls_cert or tlsCert. An empty prefix leaves the name as is.
+func (g *Generator) joinFlagName(prefix, name string) string {
+	switch {
+	case prefix == "":
+		return name
+	case g.NameStyle == "snake":
+		return prefix + "_" + name
+	case g.NameStyle == "camel":
+		return prefix + upperFirst(name)
+	}
+	return prefix + "-" + name
+}
+
+// trimFlagPrefix is the inverse of joinFlagName, returning the flag name
+// without the prefix, or "" when it is the prefix alone
+func (g *Generator) trimFlagPrefix(prefix, flag string) string {
+	if prefix == "" {
+		return flag
+	}
+	switch g.NameStyle {
+	case "snake":
+		return strings.TrimPrefix(flag, prefix+"_")
+	case "camel":
+		rest, ok := strings.CutPrefix(flag, prefix)
+		if !ok || rest == "" {
+			return rest
+		}
+		r, size := utf8.DecodeRuneInString(rest)
+		return string(unicode.ToLower(r)) + rest[size:]
+	}
+	return strings.TrimPrefix(flag, prefix+"-")
+}
+
+// upperFirst upper-cases the first letter of s
+func upperFirst(s string) string {
+	if s == "" {
+		return s
+	}
+	r, size := utf8.DecodeRuneInString(s)
+	return string(unicode.ToUpper(r)) + s[size:]
+}
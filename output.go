@@ -0,0 +1,31 @@
+package main
+
+// outputFormats lists the values of the --output flag added by
+// --with-output-format
+var outputFormats = []string{"json", "yaml", "text"}
+
+// yamlModule is the module the generated Render helper uses for yaml
+const yamlModule = "gopkg.in/yaml.v3 v3.0.1"
+
+// outputFormatField returns the --output flag choosing how Render writes
+// the command's results
+func outputFormatField() FieldInfo {
+	return FieldInfo{
+		Name:         "OutputFormat",
+		Type:         "string",
+		CLIName:      "output",
+		ShortFlag:    "o",
+		DefaultValue: "text",
+		Options:      outputFormats,
+		Usage:        "Output format",
+	}
+}
+
+// withOutputFormat appends the --output flag to the fields when the
+// generator was asked for one
+func (g *Generator) withOutputFormat(fields []FieldInfo) []FieldInfo {
+	if !g.OutputFormat {
+		return fields
+	}
+	return append(fields, outputFormatField())
+}
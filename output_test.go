@@ -0,0 +1,35 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestOutputFormat(t *testing.T) {
+	dir := generate(t, `package main
+
+type ServeArgs struct {
+	Port int `+"`cli:\"port,p,default:8080\"`"+`
+}
+`, "--with-output-format", "serve", "Starts an http server")
+	app := filepath.Join(dir, "cmd", "serve")
+	writeHandler(t, app, "serve", `if err := args.Render(map[string]int{"port": args.Port}); err != nil {
+	return err
+}`)
+	bin := buildCommand(t, app)
+
+	for _, tt := range []struct {
+		format, want string
+	}{
+		{"json", "{\n  \"port\": 8080\n}\n"},
+		{"yaml", "port: 8080\n"},
+		{"text", "map[port:8080]\n"},
+	} {
+		if out, err := runCommand(bin, "--output="+tt.format); err != nil || out != tt.want {
+			t.Errorf("--output=%s gave %q, %v, want %q", tt.format, out, err, tt.want)
+		}
+	}
+	if out, err := runCommand(bin, "--output=xml"); err == nil {
+		t.Errorf("--output=xml succeeded:\n%s", out)
+	}
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"slices"
+	"strings"
+)
+
+// presets lists the flags --preset=<name> adds to every generated command,
+// after the command's own
+var presets = map[string][]FieldInfo{
+	"standard": {
+		{Name: "Verbose", Type: "bool", CLIName: "verbose", ShortFlag: "v", Usage: "Print more details"},
+		{Name: "Quiet", Type: "bool", CLIName: "quiet", ShortFlag: "q", Usage: "Print only errors"},
+		{Name: "Config", Type: "string", CLIName: "config", Placeholder: "FILE", Usage: "Read the configuration from FILE"},
+	},
+}
+
+// withPreset appends the fields of the --preset to the command's. A field
+// of the command with the name or one of the flags of a preset field is an
+// error rather than an override, so that a preset flag never silently means
+// something else in one command.
+func (g *Generator) withPreset(fields []FieldInfo) ([]FieldInfo, error) {
+	if g.Preset == "" {
+		return fields, nil
+	}
+
+	for _, preset := range presets[g.Preset] {
+		for _, field := range fields {
+			var clash string
+			switch {
+			case strings.Split(field.Name, ".")[0] == preset.Name:
+				clash = "field " + preset.Name
+			case field.IsFlag() && (field.CLIName == preset.CLIName || slices.Contains(field.Aliases, preset.CLIName)):
+				clash = "--" + preset.CLIName
+			case field.IsFlag() && preset.ShortFlag != "" && field.ShortFlag == preset.ShortFlag:
+				clash = "-" + preset.ShortFlag
+			default:
+				continue
+			}
+			return nil, fieldErrorf(field, "clashes with the %s added by --preset=%s, rename the field or drop the preset", clash, g.Preset)
+		}
+	}
+
+	return append(fields, presets[g.Preset]...), nil
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestStandardPreset(t *testing.T) {
+	dir := writeFiles(t, map[string]string{"source.go": serveSource})
+	cligen(t, dir, "--preset=standard", "serve", "Starts an http server")
+	bin := buildCommand(t, filepath.Join(dir, "cmd", "serve"))
+
+	out, _ := runCommand(bin, "--help")
+	for _, want := range []string{"--port", "--verbose", "--quiet", "--config FILE"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("--help lacks %s:\n%s", want, out)
+		}
+	}
+}
+
+func TestPresetClash(t *testing.T) {
+	dir := writeFiles(t, map[string]string{"source.go": `package main
+
+type ServeArgs struct {
+	Quiet bool ` + "`cli:\"silent,q\"`" + `
+}
+`})
+	out, err := runCligen(dir, "--preset=standard", "serve", "Starts an http server")
+	if err == nil || !strings.Contains(out, "clashes with the field Quiet added by --preset=standard") {
+		t.Errorf("a field named like a preset field gave %v, want a clash:\n%s", err, out)
+	}
+}
+
+func TestPresetConfigClash(t *testing.T) {
+	dir := writeFiles(t, map[string]string{"source.go": `package main
+
+type ServeArgs struct {
+	Config string ` + "`cli:\"settings\"`" + `
+}
+`})
+	out, err := runCligen(dir, "--preset=standard", "serve", "Starts an http server")
+	if err == nil || !strings.Contains(out, "clashes with the field Config added by --preset=standard") {
+		t.Errorf("a Config field gave %v, want a clash with the preset:\n%s", err, out)
+	}
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Markers recording how a generated file was produced, so it can be
+// regenerated with --regen without the original go:generate directive
+const (
+	generatedByMarker = "//cligen:generated-by cligen"
+	sourceMarker      = "//cligen:source "
+)
+
+// formatInvocation renders cligen arguments as a command line that
+// splitArgs parses back into the same arguments
+func formatInvocation(args []string) string {
+	quoted := make([]string, len(args))
+	for i, arg := range args {
+		if arg == "" || strings.ContainsRune(arg, ' ') || strconv.Quote(arg) != `"`+arg+`"` {
+			// Keep the quotes inside --help="..." where they were written
+			if key, value, ok := strings.Cut(arg, "="); ok && strings.HasPrefix(key, "--") && strconv.Quote(key) == `"`+key+`"` && !strings.ContainsRune(key, ' ') {
+				quoted[i] = key + "=" + strconv.Quote(value)
+				continue
+			}
+			arg = strconv.Quote(arg)
+		}
+		quoted[i] = arg
+	}
+	return strings.Join(quoted, " ")
+}
+
+// readGeneratedBy reads the recorded invocation and source file from the
+// header of a file generated by cligen
+func readGeneratedBy(path string) (args []string, source string, err error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to open %s: %w", path, err)
+	}
+	defer func() {
+		if closeErr := file.Close(); closeErr != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to close file %s: %v\n", path, closeErr)
+		}
+	}()
+
+	// The header of a file shared with --insert records only the command
+	// that created it, so the blocks after the package clause are looked
+	// for as well
+	found, inHeader := false, true
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case !inHeader:
+			if strings.HasPrefix(line, beginSentinel) {
+				return nil, "", fmt.Errorf("%s holds commands merged with --insert, regenerate each of them with its own go:generate directive", path)
+			}
+		case strings.HasPrefix(line, generatedByMarker):
+			args, found = splitArgs(strings.TrimPrefix(line, generatedByMarker)), true
+		case strings.HasPrefix(line, sourceMarker):
+			source = strings.TrimSpace(strings.TrimPrefix(line, sourceMarker))
+		case strings.HasPrefix(line, "package "):
+			inHeader = false
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, "", fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
+	if !found {
+		return nil, "", fmt.Errorf("%s has no %s header", path, generatedByMarker)
+	}
+	return args, source, nil
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestFormatInvocationRoundTrip(t *testing.T) {
+	for _, args := range [][]string{
+		{"serve", "Starts an http server", "cmd/serve/main.go"},
+		{"--command=serve", `--help=Serves "static" files`},
+		{"serve", `Reads C:\config and "quoted" words`},
+		{"serve", "Two\nlines", ""},
+		{`--help=a\b`, `--trim-suffix="Args"`},
+	} {
+		line := formatInvocation(args)
+		if got := splitArgs(line); !slices.Equal(got, args) {
+			t.Errorf("%q formatted as %s reads back as %q", args, line, got)
+		}
+		if strings.Contains(line, "\n") {
+			t.Errorf("%q formatted over several lines: %s", args, line)
+		}
+	}
+}
+
+func TestRegenWithQuotedHelp(t *testing.T) {
+	dir := writeFiles(t, map[string]string{"source.go": serveSource})
+	help := `Serves "static" files from C:\srv`
+	cligen(t, dir, "serve", help)
+	path := filepath.Join(dir, "cmd", "serve", "main.go")
+	before := readFile(t, path)
+
+	cligen(t, dir, "--regen", "cmd/serve/main.go")
+	if after := readFile(t, path); after != before {
+		t.Errorf("--regen changed the file:\n%s", after)
+	}
+	bin := buildCommand(t, filepath.Join(dir, "cmd", "serve"))
+	if out, _ := runCommand(bin, "--help"); !strings.Contains(out, help) {
+		t.Errorf("--help lacks %q:\n%s", help, out)
+	}
+}
@@ -0,0 +1,219 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"go/token"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Spec describes commands in a YAML or JSON file, read with --spec in place
+// of an args struct. Its keys mirror the cli tag modifiers, so a spec field
+// becomes the same FieldInfo as the equivalent struct field.
+type Spec struct {
+	Commands []SpecCommand `yaml:"commands"`
+}
+
+// SpecCommand is a command of a spec, generated as if its fields were the
+// fields of an args struct
+type SpecCommand struct {
+	Name      string      `yaml:"name"`
+	Help      string      `yaml:"help"`
+	Output    string      `yaml:"output"`
+	Homepage  string      `yaml:"homepage"`
+	EnvPrefix string      `yaml:"env-prefix"`
+	Usage     string      `yaml:"usage"`
+	Fields    []SpecField `yaml:"fields"`
+}
+
+// SpecField is a flag or positional argument of a spec command. Name is the
+// field of the generated command struct, where a dotted name such as
+// TLS.Cert nests the field like a nested struct would.
+type SpecField struct {
+	Name            string   `yaml:"name"`
+	Type            string   `yaml:"type"`
+	Flag            string   `yaml:"flag"`
+	Short           string   `yaml:"short"`
+	NoShort         bool     `yaml:"noshort"`
+	Aliases         []string `yaml:"aliases"`
+	Default         string   `yaml:"default"`
+	DefaultFunc     string   `yaml:"default-func"`
+	HiddenDefault   bool     `yaml:"hidden-default"`
+	Required        bool     `yaml:"required"`
+	RequiredMessage string   `yaml:"required-message"`
+	Options         []string `yaml:"options"`
+	Usage           string   `yaml:"usage"`
+	Placeholder     string   `yaml:"placeholder"`
+	Section         string   `yaml:"section"`
+	Optional        []string `yaml:"optional"`
+	Env             string   `yaml:"env"`
+	Layout          string   `yaml:"layout"`
+	Count           bool     `yaml:"count"`
+	Max             string   `yaml:"max"`
+	Positional      bool     `yaml:"positional"`
+	Variadic        bool     `yaml:"variadic"`
+	Passthrough     bool     `yaml:"passthrough"`
+	Validate        bool     `yaml:"validate"`
+	Secret          bool     `yaml:"secret"`
+	Stdio           bool     `yaml:"stdio"`
+	Order           int      `yaml:"order"`
+	HumanSize       bool     `yaml:"humansize"`
+	HumanCount      bool     `yaml:"humancount"`
+}
+
+// loadSpec reads a spec file. JSON is read as YAML, of which it is a subset.
+// Unknown keys are rejected, as a misspelled modifier would otherwise be
+// dropped silently.
+func loadSpec(path string) (*Spec, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read spec: %w", err)
+	}
+
+	var spec Spec
+	decoder := yaml.NewDecoder(bytes.NewReader(content))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&spec); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("failed to parse spec %s: %w", path, err)
+	}
+
+	if len(spec.Commands) == 0 {
+		return nil, fmt.Errorf("spec %s declares no commands", path)
+	}
+	seen := make(map[string]bool)
+	for _, cmd := range spec.Commands {
+		if cmd.Name == "" {
+			return nil, fmt.Errorf("spec %s: every command needs a name", path)
+		}
+		if seen[cmd.Name] {
+			return nil, fmt.Errorf("spec %s: command %s is declared twice", path, cmd.Name)
+		}
+		seen[cmd.Name] = true
+	}
+
+	return &spec, nil
+}
+
+// command returns the spec command of the given name, or nil
+func (s *Spec) command(name string) *SpecCommand {
+	for i := range s.Commands {
+		if s.Commands[i].Name == name {
+			return &s.Commands[i]
+		}
+	}
+	return nil
+}
+
+// specCommand converts the fields of the spec command g.Command and checks
+// them like the fields of an args struct
+func (g *Generator) specCommand() (string, []FieldInfo, error) {
+	cmd := g.Spec.command(g.Command)
+	if cmd == nil {
+		return "", nil, fmt.Errorf("no command %s in spec %s", g.Command, g.SourceFile)
+	}
+
+	var fields []FieldInfo
+	g.prefixes = make(map[string]string)
+	for _, specField := range cmd.Fields {
+		field, err := specField.fieldInfo(g)
+		if err != nil {
+			return "", nil, fmt.Errorf("failed to parse spec fields: %w", err)
+		}
+		// The nested structs prefix their flags as they would in Go source
+		path := strings.Split(field.Name, ".")
+		for i := 1; i < len(path); i++ {
+			nested := strings.Join(path[:i], ".")
+			if _, ok := g.prefixes[nested]; !ok {
+				g.prefixes[nested] = g.joinFlagName(g.prefixes[strings.Join(path[:i-1], ".")], g.flagName(path[i-1]))
+			}
+		}
+		g.logf("spec field %s: --%s (%s)", field.Name, field.CLIName, field.Type)
+		fields = append(fields, field)
+	}
+
+	fields, err := g.withPreset(fields)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to parse spec fields: %w", err)
+	}
+	fields = orderFields(g.withOutputFormat(fields))
+	if err := g.validateFields(fields); err != nil {
+		return "", nil, fmt.Errorf("failed to parse spec fields: %w", err)
+	}
+
+	return exportedName(cmd.Name) + "Args", fields, nil
+}
+
+// fieldInfo converts a spec field. Without a flag name, the flag is named
+// like a struct field of that name, in the --name-style of g and prefixed
+// with the names of the structs holding it.
+func (f SpecField) fieldInfo(g *Generator) (FieldInfo, error) {
+	path := strings.Split(f.Name, ".")
+	for _, name := range path {
+		if !token.IsIdentifier(name) {
+			return FieldInfo{}, fmt.Errorf("field %q: name must be a Go identifier, or several joined with dots", f.Name)
+		}
+	}
+
+	if f.Type == "" {
+		return FieldInfo{}, fmt.Errorf("field %s: type is required", f.Name)
+	}
+
+	flag := f.Flag
+	if flag == "" {
+		for _, name := range path {
+			flag = g.joinFlagName(flag, g.flagName(name))
+		}
+	}
+
+	var human string
+	switch {
+	case f.HumanSize && f.HumanCount:
+		return FieldInfo{}, fmt.Errorf("field %s: humansize and humancount cannot be combined", f.Name)
+	case f.HumanSize:
+		human = "size"
+	case f.HumanCount:
+		human = "count"
+	}
+	// Defaults are turned into Go literals as for a humansize tag
+	defaultValue := f.Default
+	if value, err := parseHuman(defaultValue, human == "size"); err == nil && human != "" && defaultValue != "" {
+		defaultValue = strconv.FormatUint(value, 10)
+	}
+
+	return FieldInfo{
+		Name:            f.Name,
+		Type:            f.Type,
+		CLIName:         flag,
+		ShortFlag:       f.Short,
+		NoShort:         f.NoShort,
+		Aliases:         f.Aliases,
+		DefaultValue:    defaultValue,
+		DefaultFunc:     f.DefaultFunc,
+		HideDefault:     f.HiddenDefault,
+		Required:        f.Required || f.RequiredMessage != "",
+		RequiredMessage: f.RequiredMessage,
+		Options:         f.Options,
+		Usage:           f.Usage,
+		Placeholder:     f.Placeholder,
+		Group:           f.Section,
+		Optional:        f.Optional,
+		Env:             f.Env,
+		Layout:          f.Layout,
+		Count:           f.Count,
+		Max:             f.Max,
+		Positional:      f.Positional,
+		Variadic:        f.Variadic,
+		Passthrough:     f.Passthrough,
+		Validate:        f.Validate,
+		Secret:          f.Secret,
+		Stdio:           f.Stdio,
+		Order:           f.Order,
+		Human:           human,
+	}, nil
+}
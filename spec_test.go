@@ -0,0 +1,23 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSpecHelpWithQuotesAndPercent(t *testing.T) {
+	dir := writeFiles(t, map[string]string{"spec.yaml": `commands:
+  - name: serve
+    help: 'Serves "static" files at 100% speed'
+    fields:
+      - {name: Port, type: int, short: p, default: 8080}
+`})
+	cligen(t, dir, "--spec=spec.yaml")
+	bin := buildCommand(t, filepath.Join(dir, "cmd", "serve"))
+
+	out, _ := runCommand(bin, "--help")
+	if want := `Serves "static" files at 100% speed`; !strings.Contains(out, want) {
+		t.Errorf("--help lacks %q:\n%s", want, out)
+	}
+}
@@ -0,0 +1,21 @@
+package main
+
+import "strings"
+
+// stdioFields returns the fields with the stdio modifier, which get Open
+// and Create methods treating - as stdin and stdout
+func stdioFields(fields []FieldInfo) []FieldInfo {
+	var result []FieldInfo
+	for _, field := range fields {
+		if field.Stdio {
+			result = append(result, field)
+		}
+	}
+	return result
+}
+
+// stdioName returns the suffix of a stdio field's Open and Create methods,
+// e.g. TLSCert for TLS.Cert
+func stdioName(field FieldInfo) string {
+	return strings.ReplaceAll(field.Name, ".", "")
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestStdio(t *testing.T) {
+	dir := generate(t, `package main
+
+type CatArgs struct {
+	Input  string `+"`cli:\"input,i,stdio,default:-\"`"+`
+	Output string `+"`cli:\"output,o,stdio,default:-\"`"+`
+}
+`, "cat", "Copies the input to the output")
+	app := filepath.Join(dir, "cmd", "cat")
+	writeHandler(t, app, "cat", `in, err := args.OpenInput()
+if err != nil {
+	return err
+}
+defer in.Close()
+out, err := args.CreateOutput()
+if err != nil {
+	return err
+}
+defer out.Close()
+if _, err := io.Copy(out, in); err != nil {
+	return err
+}`, "io")
+	bin := buildCommand(t, app)
+
+	cmd := exec.Command(bin)
+	cmd.Stdin = strings.NewReader("from stdin\n")
+	if out, err := cmd.CombinedOutput(); err != nil || string(out) != "from stdin\n" {
+		t.Errorf("- as stdin and stdout gave %q, %v", out, err)
+	}
+
+	in, out := filepath.Join(dir, "in.txt"), filepath.Join(dir, "out.txt")
+	if err := os.WriteFile(in, []byte("from a file\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := runCommand(bin, "-i", in, "-o", out); err != nil || got != "" {
+		t.Errorf("-i %s -o %s gave %q, %v", in, out, got, err)
+	}
+	if got := readFile(t, out); got != "from a file\n" {
+		t.Errorf("the output file holds %q, want the input file", got)
+	}
+	if got, err := runCommand(bin, "-i", filepath.Join(dir, "missing.txt")); err == nil {
+		t.Errorf("a missing input succeeded:\n%s", got)
+	}
+
+	generateFails(t, `package main
+
+type CatArgs struct {
+	Input int `+"`cli:\"input,stdio\"`"+`
+}
+`, "stdio requires a string field, got int", "cat", "Copies the input to the output")
+}
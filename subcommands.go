@@ -0,0 +1,295 @@
+package main
+
+import (
+	"fmt"
+	"go/ast"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Command describes a command discovered in the source file
+type Command struct {
+	Name       string
+	Help       string
+	StructName string
+	Fields     []FieldInfo
+
+	// Homepage is set by a //cligen:homepage annotation
+	Homepage string
+	// EnvPrefix is set by a //cligen:env-prefix annotation
+	EnvPrefix string
+	// Deprecation is set by a //cligen:deprecated annotation
+	Deprecation *Deprecation
+	// Usage is set by a //cligen:usage annotation
+	Usage string
+}
+
+// Deprecation describes a command retired by a //cligen:deprecated
+// annotation, as in //cligen:deprecated since:2.0 use:deploy. Any other
+// words are a note shown after the warning.
+type Deprecation struct {
+	Since string
+	Use   string
+	Note  string
+}
+
+// parseDeprecation parses the value of a //cligen:deprecated annotation
+func parseDeprecation(value string) *Deprecation {
+	var d Deprecation
+	var note []string
+	for _, word := range strings.Fields(value) {
+		if since, ok := strings.CutPrefix(word, "since:"); ok {
+			d.Since = since
+		} else if use, ok := strings.CutPrefix(word, "use:"); ok {
+			d.Use = use
+		} else {
+			note = append(note, word)
+		}
+	}
+	d.Note = strings.Join(note, " ")
+	return &d
+}
+
+// Warning returns the message printed when the deprecated command runs
+func (d *Deprecation) Warning(command string) string {
+	warning := fmt.Sprintf("command %q is deprecated", command)
+	if d.Since != "" {
+		warning += " since " + d.Since
+	}
+	if d.Use != "" {
+		warning += fmt.Sprintf(", use %q instead", d.Use)
+	}
+	if d.Note != "" {
+		warning += ". " + d.Note
+	}
+	return warning
+}
+
+// readAnnotations applies the //cligen:<key> <value> annotations in the doc
+// comment of the command's struct
+func (g *Generator) readAnnotations(cmd *Command) {
+	if g.Spec != nil {
+		// A spec declares the annotations as keys of the command
+		if spec := g.Spec.command(cmd.Name); spec != nil {
+			cmd.Homepage, cmd.EnvPrefix, cmd.Usage = spec.Homepage, spec.EnvPrefix, spec.Usage
+		}
+		return
+	}
+
+	doc := g.docs[cmd.StructName]
+	if doc == nil {
+		return
+	}
+
+	for _, comment := range doc.List {
+		annotation, ok := strings.CutPrefix(comment.Text, "//cligen:")
+		if !ok {
+			continue
+		}
+
+		key, value, _ := strings.Cut(annotation, " ")
+		value = strings.TrimSpace(value)
+		switch key {
+		case "homepage":
+			cmd.Homepage = value
+		case "env-prefix":
+			cmd.EnvPrefix = value
+		case "deprecated":
+			cmd.Deprecation = parseDeprecation(value)
+		case "usage":
+			cmd.Usage = value
+		}
+	}
+}
+
+// discoverCommands finds every args struct in the file. The command name is
+// taken from the struct's //go:generate cligen directive if present, and
+// otherwise inferred from the struct name by trimming a suffix
+// (ServeCLIArgs -> serve). The help text comes from the directive or the
+// first line of the doc comment.
+func (g *Generator) discoverCommands(node *ast.File) []Command {
+	defer g.trace("discovery", g.Program, time.Now())
+
+	var commands []Command
+
+	for _, decl := range node.Decls {
+		genDecl, ok := decl.(*ast.GenDecl)
+		if !ok {
+			continue
+		}
+
+		for _, spec := range genDecl.Specs {
+			typeSpec, ok := spec.(*ast.TypeSpec)
+			if !ok {
+				continue
+			}
+			if _, ok := typeSpec.Type.(*ast.StructType); !ok {
+				continue
+			}
+
+			name := typeSpec.Name.Name
+			base, ok := g.trimSuffix(name)
+			if !ok || g.isGlobal(name) {
+				continue
+			}
+
+			cmd := Command{
+				Name:       strings.ToLower(base),
+				StructName: name,
+			}
+			if doc := g.docs[name]; doc != nil {
+				cmd.Help = docSummary(doc, name)
+				if inv, ok := directiveInvocation(doc); ok {
+					cmd.Name, cmd.Help = inv.Command, inv.Help
+				}
+			}
+			g.readAnnotations(&cmd)
+
+			g.logf("discovered command %s from struct %s", cmd.Name, name)
+			commands = append(commands, cmd)
+		}
+	}
+
+	return commands
+}
+
+// docSummary returns the first line of a doc comment as the help of a
+// command. A line starting with the declared name, as Go doc comments do,
+// has it dropped and the rest capitalized, so "ServeArgs starts a server"
+// becomes "Starts a server".
+func docSummary(doc *ast.CommentGroup, name string) string {
+	line, _, _ := strings.Cut(strings.TrimSpace(doc.Text()), "\n")
+	if rest, ok := strings.CutPrefix(line, name+" "); ok {
+		line = upperFirst(strings.TrimSpace(rest))
+	}
+	return line
+}
+
+// defaultSuffixes are trimmed from struct names to infer command names
+// unless --trim-suffix is given
+var defaultSuffixes = []string{"CLIArgs", "Args"}
+
+// trimSuffix strips the first matching command suffix from a struct name.
+// It reports false if the name has none of the suffixes or nothing is left.
+func (g *Generator) trimSuffix(name string) (string, bool) {
+	suffixes := g.TrimSuffixes
+	if len(suffixes) == 0 {
+		suffixes = defaultSuffixes
+	}
+
+	for _, suffix := range suffixes {
+		if base, ok := strings.CutSuffix(name, suffix); ok && base != "" {
+			return base, true
+		}
+	}
+
+	return "", false
+}
+
+// directiveInvocation extracts the command and help from a
+// //go:generate cligen directive in a doc comment
+func directiveInvocation(doc *ast.CommentGroup) (invocation, bool) {
+	for _, comment := range doc.List {
+		line, ok := strings.CutPrefix(comment.Text, "//go:generate cligen ")
+		if !ok {
+			continue
+		}
+
+		// Drop generator options, keeping only the command arguments
+		var args []string
+		for _, arg := range splitArgs(line) {
+			if strings.HasPrefix(arg, "-") && !strings.HasPrefix(arg, "--command=") &&
+				!strings.HasPrefix(arg, "--help=") && !strings.HasPrefix(arg, "--output=") {
+				continue
+			}
+			args = append(args, arg)
+		}
+
+		if invs, ok := parseInvocations(args); ok && invs[0].Command != "" {
+			return invs[0], true
+		}
+	}
+
+	return invocation{}, false
+}
+
+// generateSubcommands generates a single program dispatching to every
+// command discovered in the source file
+func (g *Generator) generateSubcommands(node *ast.File) error {
+	commands := g.discoverCommands(node)
+	if len(commands) == 0 {
+		return fmt.Errorf("no args structs found in %s", g.SourceFile)
+	}
+
+	if err := g.makeOutputDir(); err != nil {
+		return err
+	}
+
+	globals, prefixes, err := g.parseGlobals()
+	if err != nil {
+		return err
+	}
+
+	for i, cmd := range commands {
+		if err := g.checkGeneric(cmd.StructName); err != nil {
+			return err
+		}
+		g.dumpStruct(cmd.Name, cmd.StructName, g.structs[cmd.StructName])
+		start := time.Now()
+		fields, err := g.parseStructFields(g.structs[cmd.StructName])
+		if err != nil {
+			return fmt.Errorf("failed to parse struct fields of %s: %w", cmd.StructName, err)
+		}
+		if fields, err = g.withGlobals(fields, globals, prefixes); err != nil {
+			return fmt.Errorf("failed to parse struct fields of %s: %w", cmd.StructName, err)
+		}
+		g.trace("fields", cmd.Name, start)
+		if err := expandHelp(cmd.Name, fields); err != nil {
+			return err
+		}
+		commands[i].Fields = fields
+
+		data := g.commandData(commands[i])
+		data.Program = g.Program
+		data.LocalFlags = true
+		data.Main = false
+
+		path := filepath.Join(g.outputDir(), cmd.Name+".go")
+		if err := g.renderTemplate("cli", path, data); err != nil {
+			return err
+		}
+
+		if err := g.generateValidationStubs(cmd.Name, fields); err != nil {
+			return err
+		}
+
+		if err := g.generateImplementationStub(cmd.Name, cmd.StructName, fields); err != nil {
+			return err
+		}
+	}
+
+	data := templateData{
+		Program:    g.Program,
+		Commands:   commands,
+		Globals:    globals,
+		Backend:    g.Backend,
+		Color:      g.Color,
+		Completion: g.Completion,
+		Invocation: g.Invocation,
+		Source:     g.SourceFile,
+	}
+	if err := g.renderTemplate("root", g.OutputFile, data); err != nil {
+		return err
+	}
+
+	if err := g.generateColorHelper(); err != nil {
+		return err
+	}
+
+	if err := g.generateCompletion(g.Program, commands, true); err != nil {
+		return err
+	}
+
+	return g.generateGoMod(g.moduleName(g.Program))
+}
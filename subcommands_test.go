@@ -0,0 +1,203 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const subcommandsSource = `package main
+
+// ServeArgs serves "static" files at 100% speed
+type ServeArgs struct {
+	Port int ` + "`cli:\"port,p,default:8080\"`" + `
+}
+
+// BuildArgs builds the site
+type BuildArgs struct {
+	Out string ` + "`cli:\"out,o\"`" + `
+}
+`
+
+func TestSubcommandsRunWithoutArgs(t *testing.T) {
+	dir := writeFiles(t, map[string]string{"source.go": subcommandsSource})
+	cligen(t, dir, "--subcommands=app")
+	bin := buildCommand(t, filepath.Join(dir, "cmd", "app"))
+
+	out, err := runCommand(bin)
+	if err == nil {
+		t.Fatalf("running without a command succeeded, want the usage and a failure:\n%s", out)
+	}
+	for _, want := range []string{"Usage: app <command> [options]", "serve", "build", "Run 'app help <command>'"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("usage without a command lacks %q:\n%s", want, out)
+		}
+	}
+}
+
+func TestSubcommandsHelpWithQuotesAndPercent(t *testing.T) {
+	dir := writeFiles(t, map[string]string{"source.go": subcommandsSource})
+	cligen(t, dir, "--subcommands=app")
+	bin := buildCommand(t, filepath.Join(dir, "cmd", "app"))
+
+	want := `Serves "static" files at 100% speed`
+	out, _ := runCommand(bin, "help")
+	if !strings.Contains(out, want) {
+		t.Errorf("command list lacks %q:\n%s", want, out)
+	}
+	if strings.Contains(out, "ServeArgs") {
+		t.Errorf("command list repeats the struct name:\n%s", out)
+	}
+	out, _ = runCommand(bin, "serve", "--help")
+	if !strings.Contains(out, want) {
+		t.Errorf("serve --help lacks %q:\n%s", want, out)
+	}
+}
+
+func TestHomepageWithQuotes(t *testing.T) {
+	dir := writeFiles(t, map[string]string{"source.go": `package main
+
+// ServeArgs starts a server
+//
+//cligen:homepage https://example.com/search?q="serve"
+type ServeArgs struct {
+	Port int
+}
+`})
+	cligen(t, dir, "serve", "Starts a server")
+	bin := buildCommand(t, filepath.Join(dir, "cmd", "serve"))
+
+	out, _ := runCommand(bin, "--help")
+	if want := `See https://example.com/search?q="serve" for documentation.`; !strings.Contains(out, want) {
+		t.Errorf("--help lacks %q:\n%s", want, out)
+	}
+}
+
+func TestHelpCommand(t *testing.T) {
+	dir := writeFiles(t, map[string]string{"source.go": subcommandsSource})
+	cligen(t, dir, "--subcommands=app")
+	bin := buildCommand(t, filepath.Join(dir, "cmd", "app"))
+
+	help, err := runCommand(bin, "help", "serve")
+	if err != nil || !strings.Contains(help, "--port") {
+		t.Fatalf("help serve gave %v, want the flags of serve:\n%s", err, help)
+	}
+	if flag, _ := runCommand(bin, "serve", "--help"); flag != help {
+		t.Errorf("help serve and serve --help differ:\n%s\n%s", help, flag)
+	}
+	if out, err := runCommand(bin, "help", "deploy"); err == nil || !strings.Contains(out, "deploy") {
+		t.Errorf("help deploy gave %v, want an unknown command:\n%s", err, out)
+	}
+}
+
+func TestTrimSuffix(t *testing.T) {
+	dir := writeFiles(t, map[string]string{"source.go": `package main
+
+// BuildOptions builds the site
+type BuildOptions struct {
+	Out string
+}
+
+// ServeCLIArgs serves the site
+type ServeCLIArgs struct {
+	Port int
+}
+`})
+	cligen(t, dir, "--subcommands=app", "--trim-suffix=CLIArgs,Options")
+	bin := buildCommand(t, filepath.Join(dir, "cmd", "app"))
+
+	out, _ := runCommand(bin, "help")
+	for _, want := range []string{"build", "Builds the site", "serve", "Serves the site"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("command list lacks %q:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, "buildoptions") {
+		t.Errorf("command list keeps the suffix:\n%s", out)
+	}
+}
+
+func TestEnvPrefix(t *testing.T) {
+	dir := generate(t, `package main
+
+//cligen:env-prefix APP_
+type ServeArgs struct {
+	MaxConn int    `+"`cli:\"max-conn\"`"+`
+	Token   string `+"`cli:\"token,env:SERVICE_TOKEN\"`"+`
+}
+`, "serve", "Starts an http server")
+	app := filepath.Join(dir, "cmd", "serve")
+	writeHandler(t, app, "serve", `fmt.Println(args.MaxConn, args.Token)`)
+	bin := buildCommand(t, app)
+
+	env := []string{"APP_MAX_CONN=7", "SERVICE_TOKEN=s3cret", "APP_TOKEN=ignored"}
+	if out, err := runCommandEnv(bin, env); err != nil || out != "7 s3cret\n" {
+		t.Errorf("the environment gave %q, %v, want \"7 s3cret\\n\"", out, err)
+	}
+	if out, err := runCommandEnv(bin, env, "--max-conn=9"); err != nil || out != "9 s3cret\n" {
+		t.Errorf("--max-conn=9 gave %q, %v, want the flag over $APP_MAX_CONN", out, err)
+	}
+	if out, err := runCommandEnv(bin, []string{"APP_MAX_CONN=many"}); err == nil || !strings.Contains(out, "$APP_MAX_CONN") {
+		t.Errorf("an invalid $APP_MAX_CONN gave %v, want it named:\n%s", err, out)
+	}
+}
+
+func TestDeprecatedCommand(t *testing.T) {
+	dir := writeFiles(t, map[string]string{"source.go": `package main
+
+// RunArgs runs the site
+//
+//cligen:deprecated since:2.0 use:serve Removed in 3.0
+type RunArgs struct {
+	Port int
+}
+
+// ServeArgs serves the site
+type ServeArgs struct {
+	Port int
+}
+`})
+	cligen(t, dir, "--subcommands=app")
+	app := filepath.Join(dir, "cmd", "app")
+	writeHandler(t, app, "run", `fmt.Println("running", args.Port)`)
+	bin := buildCommand(t, app)
+
+	out, err := runCommand(bin, "run", "--port=1")
+	if err != nil {
+		t.Fatalf("run failed: %v\n%s", err, out)
+	}
+	for _, want := range []string{`Warning: command "run" is deprecated since 2.0, use "serve" instead`, "Removed in 3.0", "running 1"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("run lacks %q:\n%s", want, out)
+		}
+	}
+	if out, _ := runCommand(bin, "help"); !strings.Contains(out, "(deprecated)") {
+		t.Errorf("the command list doesn't mark run as deprecated:\n%s", out)
+	}
+	if out, _ := runCommand(bin, "serve"); strings.Contains(out, "Warning") {
+		t.Errorf("serve warns:\n%s", out)
+	}
+}
+
+func TestBinNameAndUsage(t *testing.T) {
+	dir := generate(t, serveSource, "--bin-name=mytool", "--flagset=local", "serve", "Starts an http server")
+	bin := buildCommand(t, filepath.Join(dir, "cmd", "serve"))
+	if out, _ := runCommand(bin, "--help"); !strings.Contains(out, "Usage: mytool [options]") {
+		t.Errorf("--help doesn't name the program mytool:\n%s", out)
+	}
+	if out, _ := runCommand(bin, "--nope"); !strings.Contains(out, "Run 'mytool --help' for usage") {
+		t.Errorf("an unknown flag doesn't hint at mytool --help:\n%s", out)
+	}
+
+	dir = generate(t, `package main
+
+//cligen:usage {{.Bin}} serve [options] <dir>
+type ServeArgs struct {
+	Port int
+}
+`, "--bin-name=mytool", "serve", "Starts an http server")
+	bin = buildCommand(t, filepath.Join(dir, "cmd", "serve"))
+	if out, _ := runCommand(bin, "--help"); !strings.Contains(out, "Usage: mytool serve [options] <dir>") {
+		t.Errorf("--help doesn't use the //cligen:usage line:\n%s", out)
+	}
+}
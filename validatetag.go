@@ -0,0 +1,129 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// applyValidateTag translates the rules of a go-playground/validator
+// validate tag that have a CLI equivalent, with --read-validate-tag, so one
+// set of tags drives both. What the cli tag sets wins. Rules without an
+// equivalent, such as email or dive, are left to the validator.
+func (g *Generator) applyValidateTag(field *FieldInfo, tag string) {
+	rules := g.extractTag(tag, "validate")
+	if !g.ReadValidateTag || rules == "" || rules == "-" {
+		return
+	}
+
+	bounded := field.Binding().Bits > 0 || field.Type == "string" || field.Type == "[]string"
+	for _, rule := range strings.Split(rules, ",") {
+		key, value, _ := strings.Cut(rule, "=")
+		switch {
+		case strings.Contains(rule, "|"):
+			// Alternatives can't be checked one rule at a time
+		case key == "required":
+			field.Required = true
+			continue
+		case key == "omitempty":
+			field.SkipZero = true
+			continue
+		case (key == "min" || key == "gte") && bounded:
+			field.Minimum = value
+			continue
+		case (key == "max" || key == "lte") && bounded:
+			field.Maximum = value
+			continue
+		case key == "oneof" && field.Type == "string":
+			if len(field.Options) == 0 {
+				field.Options = splitOneOf(value)
+			}
+			continue
+		}
+		g.logf("field %s: validate rule %q has no CLI equivalent, leaving it to the validator", field.Name, rule)
+	}
+}
+
+// splitOneOf splits the values of a oneof= rule, which are separated by
+// spaces and may be single-quoted to hold one, as in oneof=red 'light blue'
+func splitOneOf(value string) []string {
+	var options []string
+	for value = strings.TrimSpace(value); value != ""; value = strings.TrimSpace(value) {
+		if quoted, ok := strings.CutPrefix(value, "'"); ok {
+			if option, rest, ok := strings.Cut(quoted, "'"); ok {
+				options = append(options, option)
+				value = rest
+				continue
+			}
+		}
+		option, rest, _ := strings.Cut(value, " ")
+		options = append(options, option)
+		value = rest
+	}
+	return options
+}
+
+// Bound is a check of one of the bounds of a field from its validate tag,
+// as generated into Validate
+type Bound struct {
+	// Check is the condition of the value being out of bounds
+	Check string
+	// Message is the error returned when it is
+	Message string
+}
+
+// Bounds returns the checks of the field's minimum and maximum. Numbers
+// are bounded by their value, strings by their length in characters and
+// slices by their number of values, as the validator does.
+func (f FieldInfo) Bounds() []Bound {
+	name := "--" + f.CLIName
+	if f.Positional {
+		name = "<" + f.CLIName + ">"
+	}
+
+	value, verb, unit := "c."+f.Name, "be", ""
+	switch f.Type {
+	case "string":
+		value, unit = "len([]rune(c."+f.Name+"))", " characters long"
+	case "[]string":
+		value, verb, unit = "len(c."+f.Name+")", "have", " values"
+	}
+
+	var guards []string
+	if check := f.SectionCheck(); check != "" {
+		guards = append(guards, check)
+	}
+	if f.SkipZero {
+		// omitempty leaves the zero value unchecked
+		if f.Type == "[]string" {
+			guards = append(guards, value+" != 0")
+		} else {
+			guards = append(guards, "c."+f.Name+" != "+f.Binding().Zero)
+		}
+	}
+
+	var bounds []Bound
+	for _, b := range []struct{ limit, op, word string }{{f.Minimum, "<", "least"}, {f.Maximum, ">", "most"}} {
+		if b.limit == "" {
+			continue
+		}
+		message := fmt.Sprintf("%s must %s at %s %s%s", name, verb, b.word, b.limit, unit)
+		if b.limit == "1" {
+			message = strings.Replace(message, " values", " value", 1)
+		}
+		bounds = append(bounds, Bound{
+			Check:   strings.Join(append(guards, fmt.Sprintf("%s %s %s", value, b.op, b.limit)), " && "),
+			Message: message,
+		})
+	}
+	return bounds
+}
+
+// hasBounds reports whether any field has bounds from its validate tag
+func hasBounds(fields []FieldInfo) bool {
+	for _, field := range fields {
+		if field.Minimum != "" || field.Maximum != "" {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestReadValidateTag(t *testing.T) {
+	dir := generate(t, `package main
+
+type ServeArgs struct {
+	Port int    `+"`cli:\"port,p\" validate:\"required,min=1,max=65535\"`"+`
+	Env  string `+"`validate:\"oneof=dev prod 'pre prod'\"`"+`
+	Name string `+"`validate:\"omitempty,min=3,alphanum\"`"+`
+}
+`, "--read-validate-tag", "serve", "Starts an http server")
+	app := filepath.Join(dir, "cmd", "serve")
+	writeHandler(t, app, "serve", `fmt.Printf("%d %q %q\n", args.Port, args.Env, args.Name)`)
+	bin := buildCommand(t, app)
+
+	if out, err := runCommand(bin, "-p", "80", "--env=pre prod"); err != nil || out != "80 \"pre prod\" \"\"\n" {
+		t.Errorf("valid flags gave %q, %v", out, err)
+	}
+	for _, tt := range []struct {
+		args []string
+		want string
+	}{
+		{nil, "--port"},
+		{[]string{"-p", "70000"}, "--port must be at most 65535"},
+		{[]string{"-p", "0"}, "--port must be at least 1"},
+		{[]string{"-p", "80", "--env=qa"}, "--env must be one of: dev, prod, pre prod"},
+		{[]string{"-p", "80", "--name=ab"}, "--name"},
+	} {
+		if out, err := runCommand(bin, tt.args...); err == nil || !strings.Contains(out, tt.want) {
+			t.Errorf("%v gave %v, want an error containing %q:\n%s", tt.args, err, tt.want, out)
+		}
+	}
+
+	dir = generate(t, `package main
+
+type ServeArgs struct {
+	Port int `+"`cli:\"port,p\" validate:\"required\"`"+`
+}
+`, "serve", "Starts an http server")
+	if out, err := runCommand(buildCommand(t, filepath.Join(dir, "cmd", "serve"))); err != nil {
+		t.Errorf("without --read-validate-tag the validate tag was applied: %v\n%s", err, out)
+	}
+}
@@ -0,0 +1,527 @@
+package main
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"maps"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// backends lists the flag packages code can be generated for
+var backends = map[string]bool{
+	"pflag":   true,
+	"stdflag": true,
+	"cobra":   true,
+}
+
+// validateFields checks the parsed fields before any code is generated,
+// so that incompatible tags fail at generate time instead of producing
+// dead or broken code
+func (g *Generator) validateFields(fields []FieldInfo) error {
+	for _, field := range fields {
+		if err := validateFieldInfo(field); err != nil {
+			return err
+		}
+
+		if field.Required && field.DefaultValue != "" {
+			g.warnf("field %s: --%s is required but has default %q, so it can never be missing",
+				field.Name, field.CLIName, field.DefaultValue)
+		}
+		if field.HideDefault && field.DefaultValue == "" && field.DefaultFunc == "" {
+			g.warnf("field %s: hidden-default has no effect on --%s, which has no default", field.Name, field.CLIName)
+		}
+		if field.Required && field.DefaultFunc != "" {
+			g.warnf("field %s: --%s is required but defaults to %s(), so it is only missing when that returns the zero value",
+				field.Name, field.CLIName, field.DefaultFunc)
+		}
+	}
+
+	if err := g.strictError(); err != nil {
+		return err
+	}
+
+	if err := checkPositionals(fields); err != nil {
+		return err
+	}
+
+	if err := checkFlagNames(fields, g.reservedFlags()); err != nil {
+		return err
+	}
+
+	if err := g.checkMethodNames(fields); err != nil {
+		return err
+	}
+
+	if err := g.checkDefaultFuncs(fields); err != nil {
+		return err
+	}
+
+	return g.checkBackend(fields)
+}
+
+// validateFieldInfo checks that each modifier of a field is compatible
+// with the field's type
+func validateFieldInfo(field FieldInfo) error {
+	// Every field gets a binding, so a type without one is an error rather
+	// than a silently missing flag
+	if _, ok := typeBindings[field.Type]; !ok {
+		if field.TypeOverride != "" {
+			return fieldErrorf(field, "type:%s is not a supported type", field.TypeOverride)
+		}
+		return fieldErrorf(field, "unsupported type %s", field.Type)
+	}
+
+	if field.CLIName == "" {
+		return fieldErrorf(field, "long: needs a flag name")
+	}
+	if field.ShortFlag != "" && utf8.RuneCountInString(field.ShortFlag) != 1 {
+		return fieldErrorf(field, "short: %q must be a single character", field.ShortFlag)
+	}
+	if field.NoShort && field.ShortFlag != "" {
+		return fieldErrorf(field, "noshort conflicts with the short flag -%s", field.ShortFlag)
+	}
+
+	if b := field.Binding(); b.Bits > 0 && field.DefaultValue != "" {
+		var err error
+		if b.Unsigned {
+			_, err = strconv.ParseUint(field.DefaultValue, 0, b.Bits)
+		} else {
+			_, err = strconv.ParseInt(field.DefaultValue, 0, b.Bits)
+		}
+		if err != nil {
+			return fieldErrorf(field, "default %q is not a valid %s", field.DefaultValue, field.Type)
+		}
+	}
+
+	if field.Type == "bool" && field.DefaultValue != "" && field.DefaultValue != "true" && field.DefaultValue != "false" {
+		return fieldErrorf(field, "default %q is not a valid bool, use true/false, yes/no, on/off or 1/0", field.DefaultValue)
+	}
+
+	if field.Type == "time.Time" {
+		layout := field.Layout
+		if layout == "" {
+			layout = time.RFC3339
+		}
+		switch field.DefaultValue {
+		case "", "now", "today":
+		default:
+			if _, err := time.Parse(layout, field.DefaultValue); err != nil {
+				return fieldErrorf(field, "default %q does not match layout %q, use now or today for the current time", field.DefaultValue, layout)
+			}
+		}
+	} else if field.Layout != "" {
+		return fieldErrorf(field, "layout: requires a time.Time field, got %s", field.Type)
+	}
+
+	if len(field.Options) > 0 && field.Type != "string" {
+		if field.Type == "bool" || field.Type == "[]bool" {
+			return fieldErrorf(field, "options: cannot be used on %s fields, which only take true or false", field.Type)
+		}
+		return fieldErrorf(field, "options: requires a string field, got %s", field.Type)
+	}
+
+	// A default outside the options would fail every run that leaves the
+	// flag out
+	if len(field.Options) > 0 && field.DefaultValue != "" && !slices.Contains(field.Options, field.DefaultValue) {
+		return fieldErrorf(field, "default %q is not one of the options %s", field.DefaultValue, strings.Join(field.Options, "|"))
+	}
+
+	if field.Variadic {
+		if !field.Positional {
+			return fieldErrorf(field, "variadic requires positional")
+		}
+		if field.Type != "[]string" {
+			return fieldErrorf(field, "variadic positional must be []string, got %s", field.Type)
+		}
+	} else if field.Positional && field.Type != "string" {
+		return fieldErrorf(field, "positional must be string, got %s", field.Type)
+	}
+
+	if field.Stdio && field.Type != "string" {
+		return fieldErrorf(field, "stdio requires a string field, got %s", field.Type)
+	}
+
+	if field.DefaultFunc != "" {
+		if !field.IsFlag() {
+			return fieldErrorf(field, "default:func: only applies to flags")
+		}
+		if !token.IsIdentifier(field.DefaultFunc) {
+			return fieldErrorf(field, "default:func: %q is not a function name", field.DefaultFunc)
+		}
+		if field.DefaultValue != "" {
+			return fieldErrorf(field, "default: and default:func: cannot be combined")
+		}
+		if field.Count {
+			return fieldErrorf(field, "count flags always start at 0 and cannot have a default")
+		}
+	}
+
+	if field.Placeholder != "" {
+		if !field.IsFlag() {
+			return fieldErrorf(field, "placeholder: only applies to flags")
+		}
+		if field.Type == "bool" || field.Count {
+			return fieldErrorf(field, "placeholder: needs a flag taking a value, --%s takes none", field.CLIName)
+		}
+		if strings.ContainsAny(field.Placeholder, "` \t") {
+			return fieldErrorf(field, "placeholder: %q must be a single word without backticks", field.Placeholder)
+		}
+	}
+
+	if len(field.Optional) > 0 && (field.Positional || field.Passthrough) {
+		return fieldErrorf(field, "arguments cannot be in the optional section %s, which is only allocated for flags", field.Optional[0])
+	}
+
+	if field.HideDefault && !field.IsFlag() {
+		return fieldErrorf(field, "hidden-default only applies to flags")
+	}
+
+	if field.Env != "" && !field.IsFlag() {
+		return fieldErrorf(field, "env: only applies to flags")
+	}
+
+	if len(field.Aliases) > 0 && !field.IsFlag() {
+		return fieldErrorf(field, "alias: only applies to flags")
+	}
+
+	if field.Count {
+		if field.Type != "int" {
+			return fieldErrorf(field, "count requires an int field, got %s", field.Type)
+		}
+		if !field.IsFlag() {
+			return fieldErrorf(field, "count only applies to flags")
+		}
+		if field.DefaultValue != "" {
+			return fieldErrorf(field, "count flags always start at 0 and cannot have a default")
+		}
+	}
+
+	if field.Human != "" {
+		if field.Binding().Bits == 0 {
+			return fieldErrorf(field, "human%s requires an integer field, got %s", field.Human, field.Type)
+		}
+		if field.Count {
+			return fieldErrorf(field, "human%s cannot be combined with count", field.Human)
+		}
+		if !field.IsFlag() {
+			return fieldErrorf(field, "human%s only applies to flags", field.Human)
+		}
+	}
+
+	if field.Max != "" {
+		if !field.Count {
+			return fieldErrorf(field, "max: requires a count field")
+		}
+		if n, err := strconv.Atoi(field.Max); err != nil || n <= 0 {
+			return fieldErrorf(field, "max: %q is not a positive number", field.Max)
+		}
+	}
+
+	for _, limit := range []string{field.Minimum, field.Maximum} {
+		if limit == "" {
+			continue
+		}
+		b := field.Binding()
+		var err error
+		switch {
+		case b.Bits == 0:
+			_, err = strconv.ParseUint(limit, 10, 31)
+		case b.Unsigned:
+			_, err = strconv.ParseUint(limit, 10, b.Bits)
+		default:
+			_, err = strconv.ParseInt(limit, 10, b.Bits)
+		}
+		if err != nil {
+			return fieldErrorf(field, "validate bound %q is not a valid bound for a %s", limit, field.Type)
+		}
+	}
+
+	if field.Passthrough {
+		if field.Positional {
+			return fieldErrorf(field, "passthrough cannot be combined with positional")
+		}
+		if field.Type != "[]string" {
+			return fieldErrorf(field, "passthrough must be []string, got %s", field.Type)
+		}
+	}
+
+	return nil
+}
+
+// checkDefaultFuncs verifies that each function named by default:func: is
+// declared in the package of the generated command, where the generated
+// code can call it, and has the signature func() <field type>. The check
+// is skipped until the output directory has Go files, so the first run
+// can create it next to the implementation stub.
+func (g *Generator) checkDefaultFuncs(fields []FieldInfo) error {
+	var funcs map[string]*ast.FuncDecl
+	loaded := false
+	for _, field := range fields {
+		if field.DefaultFunc == "" {
+			continue
+		}
+
+		if !loaded {
+			var err error
+			if funcs, err = g.outputFuncs(); err != nil {
+				return err
+			}
+			loaded = true
+		}
+		if funcs == nil {
+			continue
+		}
+
+		fn, ok := funcs[field.DefaultFunc]
+		if !ok {
+			return fieldErrorf(field, "default:func: no func %s found in %s, declare it there next to the command's implementation",
+				field.DefaultFunc, g.outputDir())
+		}
+		results := fn.Type.Results
+		if fn.Type.Params.NumFields() != 0 || results.NumFields() != 1 || types.ExprString(results.List[0].Type) != field.Type {
+			return fieldErrorf(field, "default:func: %s must be a func() %s", field.DefaultFunc, field.Type)
+		}
+	}
+
+	return nil
+}
+
+// outputFuncs returns the top-level functions declared in the Go files of
+// the output directory, or nil when it has none yet, as before the first
+// generation
+func (g *Generator) outputFuncs() (map[string]*ast.FuncDecl, error) {
+	paths, err := filepath.Glob(filepath.Join(g.outputDir(), "*.go"))
+	if err != nil || len(paths) == 0 {
+		return nil, err
+	}
+
+	funcs := map[string]*ast.FuncDecl{}
+	for _, path := range paths {
+		file, err := parser.ParseFile(token.NewFileSet(), path, nil, parser.SkipObjectResolution)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+		}
+		for _, decl := range file.Decls {
+			if fn, ok := decl.(*ast.FuncDecl); ok && fn.Recv == nil {
+				funcs[fn.Name.Name] = fn
+			}
+		}
+	}
+
+	return funcs, nil
+}
+
+// checkPositionals verifies that at most one variadic positional exists
+// and that it is declared last, and that at most one field captures the
+// arguments after --
+func checkPositionals(fields []FieldInfo) error {
+	args := positionals(fields)
+
+	for i, field := range args {
+		if field.Variadic && i != len(args)-1 {
+			return fieldErrorf(field, "variadic positional must be the last positional")
+		}
+	}
+
+	seen := ""
+	for _, field := range fields {
+		if !field.Passthrough {
+			continue
+		}
+		if seen != "" {
+			return fieldErrorf(field, "only one passthrough field is allowed, %s is already one", seen)
+		}
+		seen = field.Name
+	}
+
+	return nil
+}
+
+// reservedFlags maps the long flags the generator adds to every command to
+// the option adding them
+func (g *Generator) reservedFlags() map[string]string {
+	reserved := map[string]string{}
+	if g.Color {
+		reserved["color"] = "--with-color"
+	}
+	if g.WriteConfig {
+		reserved["write-config"] = "--with-write-config"
+	}
+	return reserved
+}
+
+// checkMethodNames rejects fields named after a method generated on the
+// command, since Go doesn't allow a field and a method to share a name
+func (g *Generator) checkMethodNames(fields []FieldInfo) error {
+	methods := map[string]bool{"Execute": true, "Parse": true, "Validate": true, "Args": true, "RegisterFlags": true}
+	if g.LocalFlags || g.Backend == "cobra" || g.Program != "" {
+		methods["Usage"] = true
+	}
+	if g.Stringer {
+		methods["String"], methods["GoString"] = true, true
+	}
+	if g.OutputFormat {
+		methods["Render"] = true
+	}
+
+	for _, field := range stdioFields(fields) {
+		methods["Open"+stdioName(field)], methods["Create"+stdioName(field)] = true, true
+	}
+
+	for _, field := range fields {
+		name, _, _ := strings.Cut(field.Name, ".")
+		if methods[name] {
+			return fieldErrorf(field, "clashes with the generated %s method", name)
+		}
+	}
+
+	return nil
+}
+
+// checkFlagNames rejects flag names claimed by more than one field or by
+// the generator, which would make the flag package panic when the command
+// starts
+func checkFlagNames(fields []FieldInfo, reserved map[string]string) error {
+	owners := map[string][]FieldInfo{}
+	var names []string
+
+	claim := func(name string, owner FieldInfo) {
+		if owners[name] == nil {
+			names = append(names, name)
+		}
+		owners[name] = append(owners[name], owner)
+	}
+	// Sorted so that the error lists the names the same way every run
+	for _, name := range slices.Sorted(maps.Keys(reserved)) {
+		claim("--"+name, FieldInfo{Name: reserved[name]})
+	}
+	for _, field := range fields {
+		if !field.IsFlag() {
+			continue
+		}
+		claim("--"+field.CLIName, field)
+		for _, alias := range field.Aliases {
+			claim("--"+alias, field)
+		}
+		if field.ShortFlag != "" {
+			claim("-"+field.ShortFlag, field)
+		}
+	}
+
+	var duplicates []string
+	var at FieldInfo
+	for _, name := range names {
+		if len(owners[name]) > 1 {
+			var claimants []string
+			for _, owner := range owners[name] {
+				claimants = append(claimants, owner.Name)
+			}
+			duplicates = append(duplicates, fmt.Sprintf("%s (claimed by %s)", name, strings.Join(claimants, ", ")))
+			if at.Name == "" {
+				// Point at the field claiming the name again
+				at = owners[name][len(owners[name])-1]
+			}
+		}
+	}
+	if len(duplicates) > 0 {
+		return &sourceError{field: at, err: fmt.Errorf("duplicate flag names: %s", strings.Join(duplicates, "; "))}
+	}
+
+	return nil
+}
+
+// checkBackend rejects fields the selected backend cannot express. The
+// stdflag backend has no slice flags, no fixed-width integers below 64
+// bits and no flag grouping. Cobra prints its own help, so it has no flag
+// grouping either.
+func (g *Generator) checkBackend(fields []FieldInfo) error {
+	if g.Backend == "cobra" {
+		for _, field := range fields {
+			if field.Group != "" {
+				return fieldErrorf(field, "help sections are not supported by the cobra backend")
+			}
+		}
+	}
+
+	if g.Backend != "stdflag" {
+		return nil
+	}
+
+	for _, field := range fields {
+		if field.Passthrough {
+			return fieldErrorf(field, "passthrough is not supported by the stdflag backend, which cannot tell where -- was")
+		}
+		if field.Count {
+			return fieldErrorf(field, "count is not supported by the stdflag backend")
+		}
+		if field.Positional {
+			continue
+		}
+		// Human sizes and counts are bound through a flag.Value of any width
+		if !stdflagSupports(field.Type) && field.Human == "" {
+			return fieldErrorf(field, "type %s is not supported by the stdflag backend", field.Type)
+		}
+		if field.Group != "" {
+			return fieldErrorf(field, "help sections are not supported by the stdflag backend")
+		}
+	}
+
+	return nil
+}
+
+// tagKeys lists the keys of a struct tag in the key:"value" form that
+// reflect.StructTag parses, stopping at the first malformed pair
+func tagKeys(tag string) []string {
+	var keys []string
+	for tag != "" {
+		tag = strings.TrimLeft(tag, " ")
+		colon := strings.Index(tag, ":")
+		if colon <= 0 || colon+1 >= len(tag) || tag[colon+1] != '"' {
+			break
+		}
+		keys = append(keys, tag[:colon])
+
+		// Skip the quoted value, honoring escaped quotes
+		i := colon + 2
+		for i < len(tag) && tag[i] != '"' {
+			if tag[i] == '\\' {
+				i++
+			}
+			i++
+		}
+		if i >= len(tag) {
+			break
+		}
+		tag = tag[i+1:]
+	}
+	return keys
+}
+
+// editDistance returns the Levenshtein distance between a and b
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur := make([]int, len(b)+1)
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev = cur
+	}
+	return prev[len(b)]
+}
@@ -0,0 +1,314 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDefaultFuncCheckedOnceOutputExists(t *testing.T) {
+	dir := writeFiles(t, map[string]string{"source.go": `package main
+
+type BuildArgs struct {
+	Out string ` + "`cli:\"out,o,default:func:defaultOut\"`" + `
+}
+`})
+	// The first run creates the output directory the func goes in
+	cligen(t, dir, "build", "Builds the site")
+
+	out, err := runCligen(dir, "build", "Builds the site")
+	if err == nil || !strings.Contains(out, "no func defaultOut") {
+		t.Fatalf("regenerating without defaultOut gave %v, want a missing func error:\n%s", err, out)
+	}
+
+	defaults := "package main\n\nfunc defaultOut() string { return \"site\" }\n"
+	if err := os.WriteFile(filepath.Join(dir, "cmd", "build", "defaults.go"), []byte(defaults), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cligen(t, dir, "build", "Builds the site")
+	buildCommand(t, filepath.Join(dir, "cmd", "build"))
+}
+
+func TestRequiredWithDefaultWarns(t *testing.T) {
+	dir := writeFiles(t, map[string]string{"source.go": `package main
+
+type ServeArgs struct {
+	Port int ` + "`cli:\"port,required,default:8080\"`" + `
+}
+`})
+	out := cligen(t, dir, "serve", "Starts an http server")
+	if want := `--port is required but has default "8080", so it can never be missing`; !strings.Contains(out, want) {
+		t.Errorf("generation didn't warn %q:\n%s", want, out)
+	}
+}
+
+func TestVariadicPositional(t *testing.T) {
+	dir := generate(t, `package main
+
+type CpArgs struct {
+	Src  string   `+"`cli:\"src,positional,required\"`"+`
+	Dsts []string `+"`cli:\"dst,positional,variadic,required\"`"+`
+}
+`, "cp", "Copies files")
+	app := filepath.Join(dir, "cmd", "cp")
+	writeHandler(t, app, "cp", `fmt.Println(args.Src, args.Dsts)`)
+	bin := buildCommand(t, app)
+
+	if out, err := runCommand(bin, "a", "b", "c"); err != nil || out != "a [b c]\n" {
+		t.Errorf("cp a b c gave %q, %v, want \"a [b c]\\n\"", out, err)
+	}
+	out, err := runCommand(bin, "a")
+	if err == nil || !strings.Contains(out, "argument <dst> is required") {
+		t.Errorf("cp a gave %v, want a missing <dst>:\n%s", err, out)
+	}
+	if out, _ := runCommand(bin, "--help"); !strings.Contains(out, "cp [options] <src> <dst...>") {
+		t.Errorf("usage lacks the positionals:\n%s", out)
+	}
+}
+
+func TestBoolOptionsRejected(t *testing.T) {
+	generateFails(t, `package main
+
+type ServeArgs struct {
+	Debug bool `+"`cli:\"debug,options:true|false\"`"+`
+}
+`, "field Debug: options: cannot be used on bool fields, which only take true or false", "serve", "Starts an http server")
+}
+
+func TestPassthrough(t *testing.T) {
+	dir := generate(t, `package main
+
+type RunArgs struct {
+	Name    string   `+"`cli:\"name,positional,required\"`"+`
+	Command []string `+"`cli:\"command,passthrough\"`"+`
+}
+`, "run", "Runs a job")
+	app := filepath.Join(dir, "cmd", "run")
+	writeHandler(t, app, "run", `fmt.Printf("%s %q\n", args.Name, args.Command)`)
+	bin := buildCommand(t, app)
+
+	for _, tt := range []struct {
+		args []string
+		want string
+	}{
+		{[]string{"job", "--", "ls", "-la", "--color"}, `job ["ls" "-la" "--color"]` + "\n"},
+		{[]string{"job"}, "job []\n"},
+	} {
+		if out, err := runCommand(bin, tt.args...); err != nil || out != tt.want {
+			t.Errorf("%v gave %q, %v, want %q", tt.args, out, err, tt.want)
+		}
+	}
+}
+
+func TestDuplicateFlagNames(t *testing.T) {
+	for _, tt := range []struct {
+		fields, want string
+	}{
+		{"Env string\n\tEnvironment string `cli:\"env\"`", "duplicate flag names: --env (claimed by Env, Environment)"},
+		{"Port int `cli:\"port,p\"`\n\tPath string `cli:\"path,p\"`", "duplicate flag names: -p (claimed by Port, Path)"},
+		{"Out string `cli:\"out,alias:output\"`\n\tOutput string", "duplicate flag names: --output (claimed by Out, Output)"},
+	} {
+		generateFails(t, "package main\n\ntype ServeArgs struct {\n\t"+tt.fields+"\n}\n", tt.want, "serve", "Starts an http server")
+	}
+}
+
+func TestStrict(t *testing.T) {
+	source := `package main
+
+type ServeArgs struct {
+	Port int ` + "`cli:\"port,required,default:8080\"`" + `
+}
+`
+	generateFails(t, source, "strict mode: field Port: --port is required but has default", "--strict", "serve", "Starts an http server")
+	dir := writeFiles(t, map[string]string{"source.go": source})
+	cligen(t, dir, "serve", "Starts an http server")
+}
+
+func TestDefaultWithinOptions(t *testing.T) {
+	generateFails(t, "package main\n\ntype DeployArgs struct {\n\tEnv string `cli:\"env,default:prd,options:dev|prod\"`\n}\n",
+		`field Env: default "prd" is not one of the options dev|prod`, "deploy", "Deploys")
+
+	dir := generate(t, "package main\n\ntype DeployArgs struct {\n\tEnv string `cli:\"env,default:prod,options:dev|prod\"`\n}\n", "deploy", "Deploys")
+	app := filepath.Join(dir, "cmd", "deploy")
+	writeHandler(t, app, "deploy", `fmt.Println(args.Env)`)
+	bin := buildCommand(t, app)
+	if out, err := runCommand(bin); err != nil || out != "prod\n" {
+		t.Errorf("the default gave %q, %v, want \"prod\\n\"", out, err)
+	}
+}
+
+func TestRequiredMessage(t *testing.T) {
+	dir := generate(t, `package main
+
+type DeployArgs struct {
+	Env   string `+"`cli:\"env,required:\\\"Choose an environment, dev or prod\\\"\"`"+`
+	Token string `+"`cli:\"token,required\"`"+`
+}
+`, "deploy", "Deploys")
+	bin := buildCommand(t, filepath.Join(dir, "cmd", "deploy"))
+
+	out, err := runCommand(bin)
+	if err == nil || !strings.Contains(out, "Choose an environment, dev or prod") || !strings.Contains(out, "--token is required") {
+		t.Errorf("missing flags gave %v, want the custom message and the default one:\n%s", err, out)
+	}
+}
+
+func TestRequiredSatisfiedByEnv(t *testing.T) {
+	dir := generate(t, `package main
+
+type ServeArgs struct {
+	Token string `+"`cli:\"token,required,env:SERVICE_TOKEN\"`"+`
+	Port  int    `+"`cli:\"port,required,env:PORT\"`"+`
+}
+`, "serve", "Starts an http server")
+	app := filepath.Join(dir, "cmd", "serve")
+	writeHandler(t, app, "serve", `fmt.Printf("%q %d\n", args.Token, args.Port)`)
+	bin := buildCommand(t, app)
+
+	// Set to the zero value, the variables still count as given
+	if out, err := runCommandEnv(bin, []string{"SERVICE_TOKEN=", "PORT=0"}); err != nil || out != "\"\" 0\n" {
+		t.Errorf("the variables gave %q, %v, want them to satisfy the flags", out, err)
+	}
+	if out, err := runCommandEnv(bin, []string{"PORT=1"}); err == nil || !strings.Contains(out, "--token is required") {
+		t.Errorf("without $SERVICE_TOKEN gave %v, want --token required:\n%s", err, out)
+	}
+}
+
+func TestValidateMethod(t *testing.T) {
+	dir := generate(t, `package main
+
+type ServeArgs struct {
+	Port int    `+"`cli:\"port,default:8080\"`"+`
+	Env  string `+"`cli:\"env,required,options:dev|prod\"`"+`
+}
+`, "serve", "Starts an http server")
+	app := filepath.Join(dir, "cmd", "serve")
+	test := `package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	if err := (&ServeCommand{Env: "dev"}).Validate(); err != nil {
+		t.Errorf("a valid command failed: %v", err)
+	}
+	if err := (&ServeCommand{}).Validate(); err == nil || !strings.Contains(err.Error(), "--env is required") {
+		t.Errorf("a command without --env gave %v", err)
+	}
+	if err := (&ServeCommand{Env: "qa"}).Validate(); err == nil || !strings.Contains(err.Error(), "must be one of") {
+		t.Errorf("--env=qa gave %v", err)
+	}
+}
+`
+	if err := os.WriteFile(filepath.Join(app, "serve_test.go"), []byte(test), 0644); err != nil {
+		t.Fatal(err)
+	}
+	goTool(t, app, "test", ".")
+}
+
+func TestNoShort(t *testing.T) {
+	dir := generate(t, `package main
+
+type ServeArgs struct {
+	Port int `+"`cli:\"port,noshort\"`"+`
+}
+`, "serve", "Starts an http server")
+	bin := buildCommand(t, filepath.Join(dir, "cmd", "serve"))
+
+	if out, _ := runCommand(bin, "--help"); !strings.Contains(out, "--port") || strings.Contains(out, "-p,") {
+		t.Errorf("--help doesn't list --port as long-only:\n%s", out)
+	}
+
+	generateFails(t, `package main
+
+type ServeArgs struct {
+	Port int `+"`cli:\"port,p,noshort\"`"+`
+}
+`, "noshort conflicts with the short flag -p", "serve", "Starts an http server")
+}
+
+func TestGenericStructsRejected(t *testing.T) {
+	generateFails(t, `package main
+
+type ServeArgs[T any] struct {
+	Port T
+}
+`, "struct ServeArgs: generic argument structs are not supported", "serve", "Starts an http server")
+
+	generateFails(t, `package main
+
+type Pair[T any] struct {
+	Value T
+}
+
+type ServeArgs struct {
+	Port Pair[int]
+}
+`, "generic struct Pair[int] is not supported", "serve", "Starts an http server")
+
+	generateFails(t, `package main
+
+// ServeArgs serves the site
+type ServeArgs[T any] struct {
+	Port T
+}
+`, "struct ServeArgs: generic argument structs are not supported", "--subcommands=app")
+}
+
+func TestEveryFailureReported(t *testing.T) {
+	dir := generate(t, `package main
+
+type DeployArgs struct {
+	Token string   `+"`cli:\"token,required\"`"+`
+	Env   string   `+"`cli:\"env,options:dev|prod\"`"+`
+	Tags  []string `+"`cli:\"tags\" validate:\"max=1\"`"+`
+	Dir   string   `+"`cli:\"dir,positional,required\"`"+`
+}
+`, "--read-validate-tag", "deploy", "Deploys the site")
+	bin := buildCommand(t, filepath.Join(dir, "cmd", "deploy"))
+
+	out, err := runCommand(bin, "--env=qa", "--tags=a,b")
+	if err == nil {
+		t.Fatalf("four failures succeeded:\n%s", out)
+	}
+	for _, want := range []string{"argument <dir> is required", "--token is required", "--env must be one of: dev, prod", "--tags must have at most 1 value"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("the failures lack %q:\n%s", want, out)
+		}
+	}
+	if n := strings.Count(strings.TrimSpace(out), "\n"); n < 3 {
+		t.Errorf("the failures take %d lines, want one each:\n%s", n+1, out)
+	}
+}
+
+func TestGroupedFieldsNamedAfterThemselves(t *testing.T) {
+	dir := writeFiles(t, map[string]string{"source.go": `package main
+
+type DBConfig struct {
+	Host string
+}
+
+type ServeArgs struct {
+	Include, Exclude []string ` + "`cli:\"inc,i,usage:Patterns to match\"`" + `
+	Primary, Replica DBConfig
+}
+`})
+	out := cligen(t, dir, "serve", "Starts an http server")
+	if want := "Warning: fields Include, Exclude share a cli tag, so its flag name and short flag can't apply and each is named after its field"; !strings.Contains(out, want) {
+		t.Errorf("cligen doesn't warn about the shared tag:\n%s", out)
+	}
+	bin := buildCommand(t, filepath.Join(dir, "cmd", "serve"))
+
+	help, _ := runCommand(bin, "--help")
+	for _, want := range []string{"--include", "--exclude", "Patterns to match", "--primary-host", "--replica-host"} {
+		if !strings.Contains(help, want) {
+			t.Errorf("--help lacks %q:\n%s", want, help)
+		}
+	}
+	if strings.Contains(help, "--inc ") || strings.Contains(help, "-i,") {
+		t.Errorf("--help keeps the shared flag name:\n%s", help)
+	}
+}